@@ -0,0 +1,419 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLimitExceeded is returned by AdaptiveLimiter.TryAcquire when every slot
+// is in use.
+var ErrLimitExceeded = errors.New("rate: concurrency limit exceeded")
+
+// Outcome is how a request guarded by an AdaptiveLimiter ended.
+type Outcome int
+
+const (
+	// Success is a request served by the downstream; its latency is sampled.
+	Success Outcome = iota
+	// Dropped is a request the downstream shed or timed out, a sign of
+	// overload.
+	Dropped
+	// Ignored is a request telling nothing about the downstream load, e.g.
+	// one failing validation. It frees its slot without a sample.
+	Ignored
+)
+
+// Sample is the measurement of one request fed to an AdaptiveAlgorithm.
+type Sample struct {
+	Latency time.Duration
+	// InFlight is the number of requests in flight when the request
+	// started, itself included.
+	InFlight int
+	Dropped  bool
+}
+
+// AdaptiveAlgorithm estimates the concurrency a downstream can take. Update
+// returns the new limit from the current one and a sample; the limiter
+// clamps it to its bounds. Calls are serialized by the limiter, so an
+// algorithm may keep state, but then an instance must not be shared by
+// several limiters.
+type AdaptiveAlgorithm interface {
+	Update(limit float64, s Sample) float64
+}
+
+// AdaptiveOption configures an AdaptiveLimiter.
+type AdaptiveOption func(*adaptiveConfig)
+
+type adaptiveConfig struct {
+	initial  int
+	min      int
+	max      int
+	onChange func(int)
+}
+
+// WithInitialLimit sets the limit before any sample. Defaults to 20.
+func WithInitialLimit(n int) AdaptiveOption {
+	return func(c *adaptiveConfig) {
+		c.initial = n
+	}
+}
+
+// WithMinLimit sets the lowest limit. Defaults to 1.
+func WithMinLimit(n int) AdaptiveOption {
+	return func(c *adaptiveConfig) {
+		c.min = n
+	}
+}
+
+// WithMaxLimit sets the highest limit. Defaults to 1000.
+func WithMaxLimit(n int) AdaptiveOption {
+	return func(c *adaptiveConfig) {
+		c.max = n
+	}
+}
+
+// WithOnLimitChange sets a function called with the new limit after each
+// change, like DynamicLimit.OnChange. Calls are made one at a time, in
+// order, outside the limiter lock; changes made while a call runs are
+// reported once, with the latest limit.
+func WithOnLimitChange(fn func(limit int)) AdaptiveOption {
+	return func(c *adaptiveConfig) {
+		c.onChange = fn
+	}
+}
+
+// AdaptiveLimiter bounds the number of requests in flight to a downstream,
+// adapting the bound to the latencies and drops it observes, after Netflix's
+// concurrency-limits. Unlike a Limiter it needs no rate to be configured:
+// the limit rises while latency holds and falls when the downstream queues.
+type AdaptiveLimiter struct {
+	name string
+	alg  AdaptiveAlgorithm
+	cfg  adaptiveConfig
+
+	mu       sync.Mutex
+	estimate float64
+	limit    int
+	inFlight int
+	waiters  []*adaptiveWaiter
+
+	// notified is the last limit passed to onChange, while notifying tells
+	// that a Release is calling it.
+	notified  int
+	notifying bool
+}
+
+type adaptiveWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// AdaptiveToken is a slot held by a request. It must be released exactly
+// once; later calls do nothing.
+type AdaptiveToken struct {
+	l        *AdaptiveLimiter
+	start    time.Time
+	inFlight int
+	released atomic.Bool
+}
+
+// NewAdaptiveLimiter returns a limiter adapting its limit with alg.
+func NewAdaptiveLimiter(name string, alg AdaptiveAlgorithm, options ...AdaptiveOption) *AdaptiveLimiter {
+	cfg := adaptiveConfig{initial: 20, min: 1, max: 1000}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	cfg.min = max(cfg.min, 1)
+	cfg.max = max(cfg.max, cfg.min)
+	l := &AdaptiveLimiter{name: name, alg: alg, cfg: cfg}
+	l.limit = min(max(cfg.initial, cfg.min), cfg.max)
+	l.estimate = float64(l.limit)
+	l.notified = l.limit
+	return l
+}
+
+// Limit returns the current limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of tokens held.
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// TryAcquire returns a token if a slot is free, or ErrLimitExceeded.
+func (l *AdaptiveLimiter) TryAcquire() (*AdaptiveToken, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) > 0 || l.inFlight >= l.limit {
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	return l.newToken(), nil
+}
+
+// Acquire returns a token once a slot is free, or ctx.Err() if ctx is done
+// first. Waiters are served in arrival order.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (*AdaptiveToken, error) {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.inFlight < l.limit {
+		l.inFlight++
+		t := l.newToken()
+		l.mu.Unlock()
+		return t, nil
+	}
+	w := &adaptiveWaiter{ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.newToken(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if w.granted {
+			// lost the race with a release: hand the slot on
+			l.inFlight--
+			l.grant()
+		} else {
+			for i, other := range l.waiters {
+				if other == w {
+					l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+					break
+				}
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Release frees the slot and feeds the algorithm with latency, the time the
+// request took. A zero latency is measured from Acquire.
+func (t *AdaptiveToken) Release(outcome Outcome, latency time.Duration) {
+	if t.released.Swap(true) {
+		return
+	}
+	if latency <= 0 {
+		latency = time.Since(t.start)
+	}
+	l := t.l
+	l.mu.Lock()
+	l.inFlight--
+	changed := false
+	if outcome != Ignored {
+		l.estimate = l.alg.Update(l.estimate, Sample{
+			Latency:  latency,
+			InFlight: t.inFlight,
+			Dropped:  outcome == Dropped,
+		})
+		l.estimate = min(max(l.estimate, float64(l.cfg.min)), float64(l.cfg.max))
+		if limit := int(l.estimate); limit != l.limit {
+			l.limit = limit
+			changed = true
+		}
+	}
+	l.grant()
+	if !changed || l.cfg.onChange == nil || l.notifying {
+		l.mu.Unlock()
+		return
+	}
+	l.notifying = true
+	l.mu.Unlock()
+	l.notify()
+}
+
+// notify calls onChange until it has seen the current limit, so that a
+// Release changing the limit while another one notifies need not wait.
+func (l *AdaptiveLimiter) notify() {
+	l.mu.Lock()
+	for l.limit != l.notified {
+		limit := l.limit
+		l.notified = limit
+		l.mu.Unlock()
+		l.cfg.onChange(limit)
+		l.mu.Lock()
+	}
+	l.notifying = false
+	l.mu.Unlock()
+}
+
+// newToken must be called with l.mu held, after counting the token in
+// l.inFlight.
+func (l *AdaptiveLimiter) newToken() *AdaptiveToken {
+	return &AdaptiveToken{l: l, start: time.Now(), inFlight: l.inFlight}
+}
+
+// grant hands free slots to waiters. It must be called with l.mu held.
+func (l *AdaptiveLimiter) grant() {
+	for len(l.waiters) > 0 && l.inFlight < l.limit {
+		w := l.waiters[0]
+		l.waiters[0] = nil
+		l.waiters = l.waiters[1:]
+		w.granted = true
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// AIMD grows the limit by one per sample while the limit is in use, and
+// multiplies it by Backoff on a drop. It reacts only to drops, so it suits
+// downstreams that shed load explicitly.
+type AIMD struct {
+	// Backoff is the factor applied on a drop. Defaults to 0.9.
+	Backoff float64
+	// Timeout, if set, counts slower requests as drops.
+	Timeout time.Duration
+}
+
+func (a *AIMD) Update(limit float64, s Sample) float64 {
+	if s.Dropped || (a.Timeout > 0 && s.Latency > a.Timeout) {
+		if stale(limit, s) {
+			return limit
+		}
+		return math.Floor(limit * orDefault(a.Backoff, 0.9))
+	}
+	if float64(s.InFlight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// Vegas estimates the queue at the downstream from the latency over the
+// lowest latency seen, as TCP Vegas does, and keeps it between alpha and
+// beta requests, respectively 3 and 6 times log10 of the limit. It keeps
+// the latencies of one downstream: use a new Vegas for each limiter.
+type Vegas struct {
+	// Smoothing weighs the new limit against the current one, in (0, 1].
+	// Defaults to 1, no smoothing.
+	Smoothing float64
+	// ProbeMultiplier sets how often the lowest latency is renewed, to
+	// follow a downstream getting slower: every ProbeMultiplier times the
+	// limit samples, it becomes the lowest latency of those samples.
+	// Defaults to 30.
+	ProbeMultiplier int
+
+	noLoad    time.Duration
+	windowMin time.Duration
+	samples   int
+}
+
+func (v *Vegas) Update(limit float64, s Sample) float64 {
+	if s.Latency <= 0 {
+		return limit
+	}
+	if v.windowMin == 0 || s.Latency < v.windowMin {
+		v.windowMin = s.Latency
+	}
+	if v.noLoad == 0 || s.Latency < v.noLoad {
+		v.noLoad = s.Latency
+	}
+	if v.samples++; float64(v.samples) >= orDefault(float64(v.ProbeMultiplier), 30)*limit {
+		v.noLoad, v.windowMin, v.samples = v.windowMin, 0, 0
+	}
+
+	step := max(1, math.Floor(math.Log10(limit)))
+	var next float64
+	switch {
+	case s.Dropped:
+		next = limit - step
+	case float64(s.InFlight)*2 < limit:
+		// the limit is not in use, latency says nothing about it
+		return limit
+	default:
+		queue := math.Ceil(limit * (1 - float64(v.noLoad)/float64(s.Latency)))
+		switch {
+		case queue <= step:
+			next = limit + 6*step
+		case queue < 3*step:
+			next = limit + step
+		case queue > 6*step:
+			next = limit - step
+		default:
+			return limit
+		}
+	}
+	if next < limit && stale(limit, s) {
+		return limit
+	}
+	smoothing := orDefault(v.Smoothing, 1)
+	return limit*(1-smoothing) + next*smoothing
+}
+
+// Gradient2 compares the latency of each request with a long term average
+// and scales the limit by their ratio, plus a queue allowance so the limit
+// can grow. The average drifts up with sustained load, so Gradient2 also
+// adapts to downstreams getting slower for good. It keeps the average of
+// one downstream: use a new Gradient2 for each limiter.
+type Gradient2 struct {
+	// Tolerance is the latency increase over the average tolerated before
+	// the limit shrinks. Defaults to 1.5.
+	Tolerance float64
+	// Smoothing weighs the new limit against the current one, in (0, 1].
+	// Defaults to 0.2.
+	Smoothing float64
+	// Window is the number of samples the average spans. Defaults to 600.
+	Window int
+	// QueueSize is added to the scaled limit. Defaults to 4.
+	QueueSize float64
+
+	long    float64
+	samples int
+}
+
+func (g *Gradient2) Update(limit float64, s Sample) float64 {
+	tolerance := orDefault(g.Tolerance, 1.5)
+	smoothing := orDefault(g.Smoothing, 0.2)
+	window := orDefault(float64(g.Window), 600)
+	queue := orDefault(g.QueueSize, 4)
+
+	short := float64(s.Latency)
+	if short <= 0 {
+		return limit
+	}
+	// average the first samples evenly, then exponentially
+	g.samples++
+	g.long += (short - g.long) / min(float64(g.samples), window)
+	if g.long/short > 2 {
+		// latency recovered: let the average follow faster
+		g.long *= 0.95
+	}
+	if !s.Dropped && float64(s.InFlight) < limit/2 {
+		return limit
+	}
+
+	gradient := 0.5
+	if !s.Dropped {
+		gradient = max(0.5, min(1, tolerance*g.long/short))
+	}
+	if gradient < 1 && stale(limit, s) {
+		return limit
+	}
+	next := limit*gradient + queue
+	return limit*(1-smoothing) + next*smoothing
+}
+
+// stale reports whether s is of a request admitted above limit, so that its
+// latency or drop is due to a higher limit already backed off from.
+func stale(limit float64, s Sample) bool {
+	return float64(s.InFlight) > limit
+}
+
+// orDefault returns v, or def if v is not positive.
+func orDefault(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
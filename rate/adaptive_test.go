@@ -0,0 +1,317 @@
+package rate
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	suite.Run(t, new(TestSuiteAdaptiveLimiter))
+}
+
+type TestSuiteAdaptiveLimiter struct {
+	suite.Suite
+}
+
+// simServer is a simulated downstream serving capacity requests in parallel
+// at base latency; beyond that requests queue, and those slower than
+// timeout are dropped.
+type simServer struct {
+	capacity int
+	base     time.Duration
+	timeout  time.Duration
+}
+
+func (s simServer) serve(inFlight int) (Outcome, time.Duration) {
+	latency := s.base
+	if inFlight > s.capacity {
+		latency = s.base * time.Duration(inFlight) / time.Duration(s.capacity)
+	}
+	if s.timeout > 0 && latency > s.timeout {
+		return Dropped, s.timeout
+	}
+	return Success, latency
+}
+
+// simEvent is a client of simulate sending a request at time at, or
+// completing the request holding token.
+type simEvent struct {
+	at      time.Duration
+	seq     int
+	token   *AdaptiveToken
+	outcome Outcome
+	latency time.Duration
+}
+
+type simQueue []simEvent
+
+func (q simQueue) Len() int { return len(q) }
+func (q simQueue) Less(i, j int) bool {
+	return q[i].at < q[j].at || (q[i].at == q[j].at && q[i].seq < q[j].seq)
+}
+func (q simQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *simQueue) Push(x any)   { *q = append(*q, x.(simEvent)) }
+func (q *simQueue) Pop() any {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+// simulate drives l in virtual time for d with clients sending requests to
+// srv one after another; a rejected client retries within the base latency.
+// It returns the limit sampled every base latency. Requests still in flight
+// at d are released as Ignored.
+func simulate(l *AdaptiveLimiter, srv simServer, clients int, d time.Duration) []int {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	var q simQueue
+	seq := 0
+	push := func(ev simEvent) {
+		seq++
+		ev.seq = seq
+		heap.Push(&q, ev)
+	}
+	for range clients {
+		push(simEvent{})
+	}
+
+	var limits []int
+	var sampled time.Duration
+	for q.Len() > 0 {
+		ev := heap.Pop(&q).(simEvent)
+		if ev.at > d {
+			break
+		}
+		for ; sampled <= ev.at; sampled += srv.base {
+			limits = append(limits, l.Limit())
+		}
+		if ev.token != nil {
+			ev.token.Release(ev.outcome, ev.latency)
+			push(simEvent{at: ev.at})
+			continue
+		}
+		t, err := l.TryAcquire()
+		if err != nil {
+			push(simEvent{at: ev.at + time.Duration(rnd.Int64N(int64(srv.base))) + 1})
+			continue
+		}
+		outcome, latency := srv.serve(l.InFlight())
+		push(simEvent{at: ev.at + latency, token: t, outcome: outcome, latency: latency})
+	}
+	for _, ev := range q {
+		if ev.token != nil {
+			ev.token.Release(Ignored, 0)
+		}
+	}
+	return limits
+}
+
+// mean is the average limit over the last n samples of limits.
+func mean(limits []int, n int) float64 {
+	total := 0
+	for _, limit := range limits[len(limits)-n:] {
+		total += limit
+	}
+	return float64(total) / float64(n)
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestConverges() {
+	srv := simServer{capacity: 50, base: 10 * time.Millisecond}
+	for _, tc := range []struct {
+		name string
+		alg  AdaptiveAlgorithm
+		srv  simServer
+	}{
+		{"aimd", &AIMD{}, simServer{capacity: 50, base: 10 * time.Millisecond, timeout: 20 * time.Millisecond}},
+		{"aimd-timeout", &AIMD{Timeout: 15 * time.Millisecond}, srv},
+		{"vegas", &Vegas{}, srv},
+	} {
+		s.Run(tc.name, func() {
+			l := NewAdaptiveLimiter("test", tc.alg, WithInitialLimit(5))
+			limits := simulate(l, tc.srv, 200, 5*time.Second)
+			m := mean(limits, 200)
+			s.Greater(m, 40.0, "the limit grows to the capacity")
+			s.Less(m, 150.0, "the limit stays near the capacity")
+		})
+	}
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestCapacityDrop() {
+	for _, tc := range []struct {
+		name string
+		alg  AdaptiveAlgorithm
+	}{
+		{"aimd", &AIMD{Timeout: 15 * time.Millisecond}},
+		{"vegas", &Vegas{}},
+	} {
+		s.Run(tc.name, func() {
+			l := NewAdaptiveLimiter("test", tc.alg, WithInitialLimit(5))
+			before := simulate(l, simServer{capacity: 50, base: 10 * time.Millisecond}, 200, 5*time.Second)
+			after := simulate(l, simServer{capacity: 10, base: 10 * time.Millisecond}, 200, 5*time.Second)
+			s.Less(mean(after, 200), mean(before, 200)*0.75, "the limit follows the downstream down")
+		})
+	}
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestGradient2Spike() {
+	l := NewAdaptiveLimiter("test", &Gradient2{}, WithInitialLimit(5))
+	before := simulate(l, simServer{capacity: 50, base: 10 * time.Millisecond}, 100, 5*time.Second)
+	s.Greater(mean(before, 200), 50.0)
+
+	after := simulate(l, simServer{capacity: 10, base: 10 * time.Millisecond}, 100, 500*time.Millisecond)
+	s.Less(float64(slices.Min(after)), mean(before, 200)*0.6, "a latency spike shrinks the limit")
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestAppLimited() {
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(100))
+	simulate(l, simServer{capacity: 1000, base: time.Millisecond}, 10, time.Second)
+	s.Equal(100, l.Limit(), "a limit that is not in use does not grow")
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestBounds() {
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(50), WithMinLimit(3), WithMaxLimit(10))
+	s.Equal(10, l.Limit())
+	simulate(l, simServer{capacity: 1000, base: time.Millisecond}, 100, time.Second)
+	s.Equal(10, l.Limit())
+	simulate(l, simServer{capacity: 1, base: time.Millisecond, timeout: time.Millisecond}, 100, time.Second)
+	s.Equal(3, l.Limit())
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestOnChange() {
+	var changes []int
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(2), WithOnLimitChange(func(limit int) {
+		changes = append(changes, limit)
+	}))
+	t, err := l.TryAcquire()
+	s.Require().NoError(err)
+	t.Release(Success, time.Millisecond)
+	s.Equal([]int{3}, changes)
+
+	t, err = l.TryAcquire()
+	s.Require().NoError(err)
+	t.Release(Ignored, 0)
+	t.Release(Dropped, 0)
+	s.Equal([]int{3}, changes, "ignored outcomes and double releases change nothing")
+	s.Zero(l.InFlight())
+
+	t, err = l.TryAcquire()
+	s.Require().NoError(err)
+	t.Release(Dropped, time.Millisecond)
+	s.Equal([]int{3, 2}, changes)
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestOnChangeDoesNotBlock() {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var changes []int
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(2), WithOnLimitChange(func(limit int) {
+		mu.Lock()
+		changes = append(changes, limit)
+		first := len(changes) == 1
+		mu.Unlock()
+		if first {
+			close(entered)
+			<-unblock
+		}
+	}))
+	t1, err := l.TryAcquire()
+	s.Require().NoError(err)
+	t2, err := l.TryAcquire()
+	s.Require().NoError(err)
+
+	go t1.Release(Success, time.Millisecond)
+	<-entered
+	released := make(chan struct{})
+	go func() {
+		t2.Release(Dropped, time.Millisecond)
+		close(released)
+	}()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		s.FailNow("Release waited for a running OnChange call")
+	}
+	s.Equal(2, l.Limit())
+
+	close(unblock)
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Equal(changes, []int{3, 2})
+	}, time.Second, time.Millisecond, "the change made meanwhile is reported after")
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestAcquire() {
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(1), WithMaxLimit(1))
+	held, err := l.Acquire(context.Background())
+	s.Require().NoError(err)
+	_, err = l.TryAcquire()
+	s.Require().ErrorIs(err, ErrLimitExceeded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+
+	var wg sync.WaitGroup
+	order := make(chan int, 3)
+	for i := range 3 {
+		wg.Go(func() {
+			t, err := l.Acquire(context.Background())
+			if !s.NoError(err) {
+				return
+			}
+			order <- i
+			t.Release(Success, time.Millisecond)
+		})
+		s.Require().Eventually(func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.waiters) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	held.Release(Success, time.Millisecond)
+	wg.Wait()
+	close(order)
+	var got []int
+	for i := range order {
+		got = append(got, i)
+	}
+	s.Equal([]int{0, 1, 2}, got, "waiters are served in arrival order")
+	s.Zero(l.InFlight())
+}
+
+func (s *TestSuiteAdaptiveLimiter) TestCanceledWaiterHandsOn() {
+	l := NewAdaptiveLimiter("test", &AIMD{}, WithInitialLimit(1), WithMaxLimit(1))
+	for range 100 {
+		held, err := l.TryAcquire()
+		s.Require().NoError(err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			t, err := l.Acquire(ctx)
+			if err == nil {
+				t.Release(Ignored, 0)
+			}
+			done <- err
+		}()
+		s.Require().Eventually(func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.waiters) == 1
+		}, time.Second, time.Microsecond)
+		cancel()
+		held.Release(Ignored, 0)
+		err = <-done
+		s.True(err == nil || errors.Is(err, context.Canceled))
+		s.Zero(l.InFlight(), "a slot granted to a canceled waiter is not lost")
+	}
+}
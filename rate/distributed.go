@@ -0,0 +1,237 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yeluyang/gopkg/routine"
+	"golang.org/x/time/rate"
+)
+
+// DistributedOption configures a DistributedLimiter.
+type DistributedOption func(*distributedConfig)
+
+type distributedConfig struct {
+	algorithm    Algorithm
+	key          string
+	storeTimeout time.Duration
+	replicas     int
+	onStoreError func(error)
+}
+
+// WithAlgorithm sets the algorithm applied by the store. Defaults to
+// TokenBucket.
+func WithAlgorithm(alg Algorithm) DistributedOption {
+	return func(c *distributedConfig) {
+		c.algorithm = alg
+	}
+}
+
+// WithStoreKey sets the key shared by the replicas. Defaults to the limiter
+// name.
+func WithStoreKey(key string) DistributedOption {
+	return func(c *distributedConfig) {
+		c.key = key
+	}
+}
+
+// WithStoreTimeout bounds each store call. Defaults to 100ms.
+func WithStoreTimeout(d time.Duration) DistributedOption {
+	return func(c *distributedConfig) {
+		c.storeTimeout = d
+	}
+}
+
+// WithFallbackReplicas sets the number of replicas sharing the limit, so
+// that while the store is unreachable each replica falls back to a local
+// limiter allowing its share of the limit. Defaults to 1, the full limit.
+func WithFallbackReplicas(n int) DistributedOption {
+	return func(c *distributedConfig) {
+		c.replicas = n
+	}
+}
+
+// WithOnStoreError sets a function called with every store error, before
+// falling back to the local limiter.
+func WithOnStoreError(fn func(error)) DistributedOption {
+	return func(c *distributedConfig) {
+		c.onStoreError = fn
+	}
+}
+
+// DistributedLimiter enforces a limit across every replica sharing its
+// Store. The limit and burst follow a DynamicLimit or DynamicLimitBurst
+// refreshed like Limiter.
+// While the store fails, requests are decided by a local limiter instead.
+type DistributedLimiter struct {
+	name  string
+	store Store
+	cfg   distributedConfig
+
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	fallback *rate.Limiter
+
+	stop       chan struct{}
+	ticker     *time.Ticker
+	dynLimiter DynamicLimitBurst
+}
+
+// NewDistributedLimiter returns a limiter shared through store, with the
+// limit of dynLimiter refreshed every refreshInterval. The burst follows the
+// limit as in NewDynamicLimiter2. If dynLimiter is a LimitWatcher, pushed
+// limits apply immediately.
+func NewDistributedLimiter(
+	name string,
+	store Store,
+	refreshInterval time.Duration,
+	dynLimiter DynamicLimit,
+	options ...DistributedOption,
+) *DistributedLimiter {
+	return NewDistributedBurstLimiter(name, store, refreshInterval, limitOnly{dynLimiter}, options...)
+}
+
+// NewDistributedBurstLimiter is NewDistributedLimiter with the limit and
+// burst given by dynLimiter. If dynLimiter is a SettingWatcher, pushed
+// settings apply immediately; a refreshInterval of zero then disables
+// polling. Otherwise it panics if refreshInterval is not positive, as
+// time.NewTicker.
+func NewDistributedBurstLimiter(
+	name string,
+	store Store,
+	refreshInterval time.Duration,
+	dynLimiter DynamicLimitBurst,
+	options ...DistributedOption,
+) *DistributedLimiter {
+	cfg := distributedConfig{algorithm: TokenBucket, key: name, storeTimeout: 100 * time.Millisecond, replicas: 1}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	cfg.replicas = max(cfg.replicas, 1)
+
+	l := &DistributedLimiter{
+		name:       name,
+		store:      store,
+		cfg:        cfg,
+		stop:       make(chan struct{}),
+		dynLimiter: dynLimiter,
+	}
+	l.ticker = newRefreshTicker(refreshInterval, dynLimiter)
+	setting := dynLimiter.Setting()
+	l.limit, l.burst = setting.Limit, setting.Burst
+	l.fallback = rate.NewLimiter(l.fallbackLimit(l.limit), l.burst)
+	routine.Go(func() { l.run() })
+	return l
+}
+
+// Limit returns the current limit.
+func (l *DistributedLimiter) Limit() rate.Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Burst returns the current burst.
+func (l *DistributedLimiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// Allow reports whether one event may happen now.
+func (l *DistributedLimiter) Allow() bool { return l.AllowN(time.Now(), 1) }
+
+// AllowN reports whether n events may happen at time t.
+func (l *DistributedLimiter) AllowN(t time.Time, n int) bool {
+	res, err := l.take(context.Background(), t, n)
+	if err != nil {
+		return l.fallback.AllowN(t, n)
+	}
+	return res.Allowed
+}
+
+// Wait blocks until one event is allowed or ctx is done.
+func (l *DistributedLimiter) Wait(ctx context.Context) error { return l.WaitN(ctx, 1) }
+
+// WaitN blocks until n events are allowed or ctx is done. It returns an error
+// if n exceeds the burst, or if ctx would be done before the events could be
+// allowed.
+func (l *DistributedLimiter) WaitN(ctx context.Context, n int) error {
+	for {
+		res, err := l.take(ctx, time.Now(), n)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return l.fallback.WaitN(ctx, n)
+		}
+		if res.Allowed {
+			return nil
+		}
+		if res.RetryAfter == rate.InfDuration {
+			return fmt.Errorf("rate: Wait(n=%d) can never be allowed by limiter %q", n, l.name)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < res.RetryAfter {
+			return fmt.Errorf("rate: Wait(n=%d) would exceed context deadline", n)
+		}
+
+		t := time.NewTimer(res.RetryAfter)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops refreshing the limit.
+func (l *DistributedLimiter) Close() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+	close(l.stop)
+}
+
+func (l *DistributedLimiter) take(ctx context.Context, t time.Time, n int) (Result, error) {
+	l.mu.Lock()
+	req := Request{Key: l.cfg.key, Limit: l.limit, Burst: l.burst, N: n, Now: t}
+	l.mu.Unlock()
+	if req.Limit == rate.Inf {
+		return Result{Allowed: true, Remaining: req.Burst}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.cfg.storeTimeout)
+	defer cancel()
+	res, err := l.store.Take(ctx, l.cfg.algorithm, req)
+	if err != nil && l.cfg.onStoreError != nil {
+		l.cfg.onStoreError(err)
+	}
+	return res, err
+}
+
+func (l *DistributedLimiter) fallbackLimit(limit rate.Limit) rate.Limit {
+	if limit == rate.Inf {
+		return limit
+	}
+	return limit / rate.Limit(l.cfg.replicas)
+}
+
+func (l *DistributedLimiter) run() {
+	var tick <-chan time.Time
+	if l.ticker != nil {
+		tick = l.ticker.C
+	}
+	last := Setting{Limit: l.limit, Burst: l.burst}
+	refresh(l.stop, tick, l.dynLimiter, last, func(cur Setting) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.limit = cur.Limit
+		l.burst = cur.Burst
+		l.fallback.SetLimit(l.fallbackLimit(cur.Limit))
+		l.fallback.SetBurst(cur.Burst)
+	})
+}
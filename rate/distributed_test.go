@@ -0,0 +1,144 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+func TestDistributedLimiter(t *testing.T) {
+	suite.Run(t, new(TestSuiteDistributedLimiter))
+}
+
+type TestSuiteDistributedLimiter struct {
+	suite.Suite
+}
+
+func (s *TestSuiteDistributedLimiter) TestSharedAcrossReplicas() {
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		s.Run(alg.String(), func() {
+			srv := newFakeRedis(s.T())
+			var allowed int
+			for range 3 {
+				store := NewRedisStore(srv.addr())
+				defer store.Close()
+				l := NewDistributedLimiter("api", store, time.Hour, staticLimit(5), WithAlgorithm(alg))
+				defer l.Close()
+				for range 5 {
+					if l.Allow() {
+						allowed++
+					}
+				}
+			}
+			s.Equal(5, allowed, "replicas must share one burst")
+		})
+	}
+}
+
+func (s *TestSuiteDistributedLimiter) TestWait() {
+	l := NewDistributedLimiter("wait", NewMemoryStore(), time.Hour, staticLimit(20))
+	defer l.Close()
+
+	start := time.Now()
+	for range 25 {
+		s.Require().NoError(l.Wait(context.Background()))
+	}
+	s.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for range 20 {
+		l.Allow()
+	}
+	s.Error(l.Wait(ctx))
+	s.Error(l.WaitN(context.Background(), 21), "n exceeds burst")
+}
+
+func (s *TestSuiteDistributedLimiter) TestFallback() {
+	var storeErrs atomic.Int32
+	store := failingStore{err: errors.New("connection refused")}
+	l := NewDistributedLimiter("fallback", store, time.Hour, staticLimit(10),
+		WithFallbackReplicas(2), WithOnStoreError(func(error) { storeErrs.Add(1) }))
+	defer l.Close()
+
+	allowed := 0
+	now := time.Now()
+	for range 20 {
+		if l.AllowN(now, 1) {
+			allowed++
+		}
+	}
+	s.Equal(10, allowed, "local fallback keeps the burst")
+	s.Equal(int32(20), storeErrs.Load())
+	s.Equal(rate.Limit(5), l.fallback.Limit(), "local fallback allows a share of the limit")
+	s.NoError(l.Wait(context.Background()))
+}
+
+func (s *TestSuiteDistributedLimiter) TestUnreachableRedisFallsBack() {
+	srv := newFakeRedis(s.T())
+	addr := srv.addr()
+	srv.close()
+
+	store := NewRedisStore(addr)
+	defer store.Close()
+	l := NewDistributedLimiter("down", store, time.Hour, staticLimit(1))
+	defer l.Close()
+	s.True(l.Allow())
+	s.False(l.Allow())
+}
+
+func (s *TestSuiteDistributedLimiter) TestDynamicLimit() {
+	var limit atomic.Int64
+	limit.Store(1)
+	changed := make(chan rate.Limit, 1)
+	l := NewDistributedLimiter("dynamic", NewMemoryStore(), 10*time.Millisecond, newDynamicLimit(
+		func() rate.Limit { return rate.Limit(limit.Load()) },
+		func(l rate.Limit) { changed <- l },
+	))
+	defer l.Close()
+	s.Equal(rate.Limit(1), l.Limit())
+
+	limit.Store(3)
+	s.Equal(rate.Limit(3), <-changed)
+	s.Equal(3, l.Burst())
+	now := time.Now()
+	s.True(l.AllowN(now, 3))
+	s.False(l.AllowN(now, 1))
+}
+
+func (s *TestSuiteDistributedLimiter) TestDynamicBurst() {
+	var setting atomic.Pointer[Setting]
+	setting.Store(&Setting{Limit: 1, Burst: 4})
+	changed := make(chan Setting, 1)
+	l := NewDistributedBurstLimiter("burst", NewMemoryStore(), 10*time.Millisecond, &dynamicSetting{
+		setting:  func() Setting { return *setting.Load() },
+		onChange: func(_, new Setting) { changed <- new },
+	})
+	defer l.Close()
+	s.Equal(rate.Limit(1), l.Limit())
+	s.Equal(4, l.Burst())
+	now := time.Now()
+	s.True(l.AllowN(now, 4), "the initial burst is the configured one")
+
+	setting.Store(&Setting{Limit: 2, Burst: 6})
+	s.Equal(Setting{Limit: 2, Burst: 6}, <-changed)
+	s.Equal(6, l.Burst())
+	s.False(l.AllowN(now.Add(time.Hour), 7))
+	s.True(l.AllowN(now.Add(time.Hour), 6))
+}
+
+type staticLimit rate.Limit
+
+func (l staticLimit) Limit() rate.Limit   { return rate.Limit(l) }
+func (l staticLimit) OnChange(rate.Limit) {}
+
+type failingStore struct{ err error }
+
+func (s failingStore) Take(context.Context, Algorithm, Request) (Result, error) {
+	return Result{}, s.err
+}
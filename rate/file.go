@@ -0,0 +1,226 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeluyang/gopkg/routine"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// FileOption configures a FileLimits.
+type FileOption func(*fileConfig)
+
+type fileConfig struct {
+	pollInterval time.Duration
+	onError      func(error)
+}
+
+// WithPollInterval sets how often the file is checked for changes. Defaults
+// to one second.
+func WithPollInterval(d time.Duration) FileOption {
+	return func(c *fileConfig) {
+		c.pollInterval = d
+	}
+}
+
+// WithFileErrorHandler sets a function called when the file cannot be
+// reloaded. The same error is reported once until the file loads again.
+func WithFileErrorHandler(fn func(error)) FileOption {
+	return func(c *fileConfig) {
+		c.onError = fn
+	}
+}
+
+// FileLimits serves the settings of limiters from a JSON or YAML file
+// mapping limiter names to a limit, or to a limit and a burst:
+//
+//	api: 100
+//	search: {limit: 10, burst: 20}
+//	admin: inf
+//
+// The file is polled for changes of its modification time or size, and the
+// new settings are pushed to the limiters at once. A file that fails to load
+// leaves the last good settings in place.
+type FileLimits struct {
+	path string
+	cfg  fileConfig
+
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	settings map[string]Setting
+	limits   []fileLimit
+	lastErr  string
+
+	stop   chan struct{}
+	ticker *time.Ticker
+}
+
+type fileLimit struct {
+	name  string
+	def   Setting
+	limit *AtomicLimit
+}
+
+// NewFileLimits loads the file at path and starts watching it. It returns an
+// error if the first load fails.
+func NewFileLimits(path string, options ...FileOption) (*FileLimits, error) {
+	cfg := fileConfig{pollInterval: time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	f := &FileLimits{path: path, cfg: cfg, stop: make(chan struct{})}
+	if err := f.reload(true); err != nil {
+		return nil, err
+	}
+	f.ticker = time.NewTicker(cfg.pollInterval)
+	routine.Go(func() { f.run() })
+	return f, nil
+}
+
+// For returns the setting of the limiter named name, or def while the file
+// has no entry for it. Pass it to NewDynamicBurstLimiter2 or
+// NewDistributedBurstLimiter.
+func (f *FileLimits) For(name string, def Setting) DynamicLimitBurst {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	setting, ok := f.settings[name]
+	if !ok {
+		setting = def
+	}
+	l := fileLimit{name: name, def: def, limit: NewAtomicLimit(setting)}
+	f.limits = append(f.limits, l)
+	return l.limit
+}
+
+// Close stops watching the file. Limiters keep their last setting.
+func (f *FileLimits) Close() {
+	f.ticker.Stop()
+	close(f.stop)
+}
+
+func (f *FileLimits) run() {
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-f.ticker.C:
+			if err := f.reload(false); err != nil {
+				f.report(err)
+			}
+		}
+	}
+}
+
+// reload parses the file if it changed since the last load, or always if
+// force, and pushes the new settings.
+func (f *FileLimits) reload(force bool) error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("rate: %w", err)
+	}
+	f.mu.Lock()
+	changed := !info.ModTime().Equal(f.modTime) || info.Size() != f.size
+	f.mu.Unlock()
+	if !force && !changed {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("rate: %w", err)
+	}
+	var entries map[string]fileEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("rate: parse %s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.modTime, f.size, f.lastErr = info.ModTime(), info.Size(), ""
+	f.settings = make(map[string]Setting, len(entries))
+	for name, e := range entries {
+		f.settings[name] = Setting(e)
+	}
+	for _, l := range f.limits {
+		setting, ok := f.settings[l.name]
+		if !ok {
+			setting = l.def
+		}
+		l.limit.Store(setting)
+	}
+	return nil
+}
+
+func (f *FileLimits) report(err error) {
+	f.mu.Lock()
+	repeated := err.Error() == f.lastErr
+	f.lastErr = err.Error()
+	f.mu.Unlock()
+	if !repeated && f.cfg.onError != nil {
+		f.cfg.onError(err)
+	}
+}
+
+// fileEntry is a Setting written either as a bare limit or as a mapping with
+// a limit and an optional burst.
+type fileEntry Setting
+
+func (e *fileEntry) UnmarshalYAML(node *yaml.Node) error {
+	var limitNode *yaml.Node
+	var burst *int
+	switch node.Kind {
+	case yaml.ScalarNode:
+		limitNode = node
+	case yaml.MappingNode:
+		var m struct {
+			Limit yaml.Node `yaml:"limit"`
+			Burst *int      `yaml:"burst"`
+		}
+		if err := node.Decode(&m); err != nil {
+			return err
+		}
+		if m.Limit.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: missing limit", node.Line)
+		}
+		limitNode, burst = &m.Limit, m.Burst
+	default:
+		return fmt.Errorf("line %d: expected a limit or a mapping", node.Line)
+	}
+
+	limit, err := parseLimit(limitNode.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", limitNode.Line, err)
+	}
+	e.Limit, e.Burst = limit, defaultBurst(limit)
+	if burst != nil {
+		if *burst < 0 {
+			return fmt.Errorf("line %d: negative burst %d", node.Line, *burst)
+		}
+		e.Burst = *burst
+	}
+	return nil
+}
+
+// parseLimit parses events per second, or "inf" for no limit.
+func parseLimit(s string) (rate.Limit, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, "+")) {
+	case "inf", ".inf":
+		return rate.Inf, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("negative limit %s", s)
+	}
+	return rate.Limit(v), nil
+}
@@ -0,0 +1,114 @@
+package rate
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+func TestFileLimits(t *testing.T) {
+	suite.Run(t, new(TestSuiteFileLimits))
+}
+
+type TestSuiteFileLimits struct {
+	suite.Suite
+	path string
+	gen  int
+}
+
+func (s *TestSuiteFileLimits) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "limits.yaml")
+	s.gen = 0
+}
+
+// write replaces the file, moving its modification time forward so the
+// change is seen even on coarse-grained file systems.
+func (s *TestSuiteFileLimits) write(content string) {
+	s.Require().NoError(os.WriteFile(s.path, []byte(content), 0o644))
+	s.gen++
+	mtime := time.Now().Add(time.Duration(s.gen) * time.Second)
+	s.Require().NoError(os.Chtimes(s.path, mtime, mtime))
+}
+
+func (s *TestSuiteFileLimits) TestYAML() {
+	s.write("api: 100\nsearch: {limit: 0.5, burst: 20}\nadmin: inf\n")
+	f, err := NewFileLimits(s.path, WithPollInterval(time.Hour))
+	s.Require().NoError(err)
+	defer f.Close()
+
+	def := Setting{Limit: 1, Burst: 1}
+	s.Equal(Setting{Limit: 100, Burst: 100}, f.For("api", def).Setting())
+	s.Equal(Setting{Limit: 0.5, Burst: 20}, f.For("search", def).Setting())
+	s.Equal(rate.Inf, f.For("admin", def).Setting().Limit)
+	s.Equal(def, f.For("other", def).Setting())
+}
+
+func (s *TestSuiteFileLimits) TestJSON() {
+	s.write(`{"api": 100, "search": {"limit": 10, "burst": 2}, "admin": "inf"}`)
+	f, err := NewFileLimits(s.path, WithPollInterval(time.Hour))
+	s.Require().NoError(err)
+	defer f.Close()
+
+	s.Equal(Setting{Limit: 100, Burst: 100}, f.For("api", Setting{}).Setting())
+	s.Equal(Setting{Limit: 10, Burst: 2}, f.For("search", Setting{}).Setting())
+	s.Equal(rate.Inf, f.For("admin", Setting{}).Setting().Limit)
+}
+
+func (s *TestSuiteFileLimits) TestInvalid() {
+	for _, content := range []string{
+		"api: -1\n",
+		"api: fast\n",
+		"api: {burst: 2}\n",
+		"api: {limit: 1, burst: -2}\n",
+		"api: [1]\n",
+		"- api\n",
+	} {
+		s.write(content)
+		_, err := NewFileLimits(s.path)
+		s.Error(err, content)
+	}
+	_, err := NewFileLimits(filepath.Join(s.T().TempDir(), "missing.yaml"))
+	s.Error(err)
+}
+
+func (s *TestSuiteFileLimits) TestReload() {
+	s.write("api: 1\n")
+	var mu sync.Mutex
+	var errs []error
+	f, err := NewFileLimits(s.path, WithPollInterval(5*time.Millisecond), WithFileErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	s.Require().NoError(err)
+	defer f.Close()
+
+	rl := NewDynamicBurstLimiter2("api", 0, f.For("api", Setting{Limit: 2, Burst: 2}))
+	defer rl.Close()
+	s.Require().Equal(rate.Limit(1), rl.Limit())
+
+	s.write("api: {limit: 30, burst: 3}\n")
+	s.Require().Eventually(func() bool { return rl.Limit() == 30 && rl.Burst() == 3 },
+		time.Second, time.Millisecond)
+
+	s.write("api: [\n")
+	s.Require().Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	s.Len(errs, 1, "a persisting error is reported once")
+	mu.Unlock()
+	s.Equal(rate.Limit(30), rl.Limit(), "a bad file keeps the last good settings")
+
+	s.write("other: 5\n")
+	s.Require().Eventually(func() bool { return rl.Limit() == 2 && rl.Burst() == 2 },
+		time.Second, time.Millisecond, "a removed entry falls back to its default")
+}
@@ -5,7 +5,9 @@ go 1.25.1
 require (
 	github.com/stretchr/testify v1.11.1
 	github.com/yeluyang/gopkg/routine v0.0.0-20251111064608-b8c02a3befab
+	github.com/yuin/gopher-lua v1.1.1
 	golang.org/x/time v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,5 +16,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/rogpeppe/go-internal v1.12.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,240 @@
+package rate
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyedDynamicLimit resolves the limit of each key of a KeyedLimiter, like
+// DynamicLimit does for a Limiter. Limit is called with a shard locked and
+// must be fast, e.g. a map lookup with a default.
+type KeyedDynamicLimit[K comparable] interface {
+	Limit(key K) rate.Limit
+	OnChange(key K, limit rate.Limit)
+}
+
+// KeyedLimitFunc is a KeyedDynamicLimit ignoring changes.
+type KeyedLimitFunc[K comparable] func(key K) rate.Limit
+
+func (f KeyedLimitFunc[K]) Limit(key K) rate.Limit { return f(key) }
+func (f KeyedLimitFunc[K]) OnChange(K, rate.Limit) {}
+
+// KeyedOption configures a KeyedLimiter.
+type KeyedOption func(*keyedConfig)
+
+type keyedConfig struct {
+	shards  int
+	maxKeys int
+	idleTTL time.Duration
+}
+
+// WithShards sets the number of independently locked shards keys are
+// spread over. Defaults to 32.
+func WithShards(n int) KeyedOption {
+	return func(c *keyedConfig) {
+		c.shards = n
+	}
+}
+
+// WithMaxKeys bounds the number of buckets kept in memory; the least
+// recently used buckets are evicted first. The bound is split evenly across
+// shards. Defaults to 0, unbounded.
+func WithMaxKeys(n int) KeyedOption {
+	return func(c *keyedConfig) {
+		c.maxKeys = n
+	}
+}
+
+// WithIdleTTL evicts buckets not used for d. An evicted bucket starts full
+// again when its key comes back, so d should exceed the time a bucket takes
+// to refill. Defaults to 10 minutes; zero disables it.
+func WithIdleTTL(d time.Duration) KeyedOption {
+	return func(c *keyedConfig) {
+		c.idleTTL = d
+	}
+}
+
+// KeyedLimiter keeps a separate token bucket per key, e.g. per tenant or per
+// client IP. Buckets are created on first use with the limit resolved for
+// their key, which is resolved again on use once refreshInterval has passed.
+// Idle buckets are evicted lazily, so a KeyedLimiter needs no Close.
+type KeyedLimiter[K comparable] struct {
+	name            string
+	refreshInterval time.Duration
+	dynLimiter      KeyedDynamicLimit[K]
+	cfg             keyedConfig
+
+	seed     maphash.Seed
+	shards   []keyedShard[K]
+	perShard int
+}
+
+type keyedShard[K comparable] struct {
+	mu    sync.Mutex
+	items map[K]*list.Element
+	lru   list.List // of *keyedBucket[K], most recently used first
+}
+
+type keyedBucket[K comparable] struct {
+	key      K
+	lim      *rate.Limiter
+	limit    rate.Limit
+	resolved time.Time
+	lastUsed time.Time
+}
+
+// NewKeyedLimiter returns a KeyedLimiter resolving the limit of each key with
+// dynLimiter.
+func NewKeyedLimiter[K comparable](
+	name string,
+	refreshInterval time.Duration,
+	dynLimiter KeyedDynamicLimit[K],
+	options ...KeyedOption,
+) *KeyedLimiter[K] {
+	cfg := keyedConfig{shards: 32, idleTTL: 10 * time.Minute}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	cfg.shards = max(cfg.shards, 1)
+
+	l := &KeyedLimiter[K]{
+		name:            name,
+		refreshInterval: refreshInterval,
+		dynLimiter:      dynLimiter,
+		cfg:             cfg,
+		seed:            maphash.MakeSeed(),
+		shards:          make([]keyedShard[K], cfg.shards),
+	}
+	if cfg.maxKeys > 0 {
+		l.perShard = max((cfg.maxKeys+cfg.shards-1)/cfg.shards, 1)
+	}
+	for i := range l.shards {
+		l.shards[i].items = make(map[K]*list.Element)
+	}
+	return l
+}
+
+// Allow reports whether one event of key may happen now.
+func (l *KeyedLimiter[K]) Allow(key K) bool { return l.AllowN(key, time.Now(), 1) }
+
+// AllowN reports whether n events of key may happen at time t.
+func (l *KeyedLimiter[K]) AllowN(key K, t time.Time, n int) bool {
+	return l.limiter(key, t).AllowN(t, n)
+}
+
+// Wait blocks until one event of key is allowed or ctx is done.
+func (l *KeyedLimiter[K]) Wait(ctx context.Context, key K) error { return l.WaitN(ctx, key, 1) }
+
+// WaitN blocks until n events of key are allowed or ctx is done.
+func (l *KeyedLimiter[K]) WaitN(ctx context.Context, key K, n int) error {
+	return l.limiter(key, time.Now()).WaitN(ctx, n)
+}
+
+// Reserve reserves one event of key, see rate.Limiter.Reserve.
+func (l *KeyedLimiter[K]) Reserve(key K) *rate.Reservation { return l.ReserveN(key, time.Now(), 1) }
+
+// ReserveN reserves n events of key at time t, see rate.Limiter.ReserveN.
+func (l *KeyedLimiter[K]) ReserveN(key K, t time.Time, n int) *rate.Reservation {
+	return l.limiter(key, t).ReserveN(t, n)
+}
+
+// Limit returns the current limit of key, without creating its bucket.
+func (l *KeyedLimiter[K]) Limit(key K) rate.Limit {
+	s := l.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		return e.Value.(*keyedBucket[K]).limit
+	}
+	return l.dynLimiter.Limit(key)
+}
+
+// Len returns the number of buckets in memory, including idle ones not
+// evicted yet.
+func (l *KeyedLimiter[K]) Len() int {
+	n := 0
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (l *KeyedLimiter[K]) shard(key K) *keyedShard[K] {
+	if len(l.shards) == 1 {
+		return &l.shards[0]
+	}
+	return &l.shards[maphash.Comparable(l.seed, key)%uint64(len(l.shards))]
+}
+
+// limiter returns the bucket of key, creating or refreshing it as needed.
+func (l *KeyedLimiter[K]) limiter(key K, now time.Time) *rate.Limiter {
+	s := l.shard(key)
+	s.mu.Lock()
+	l.evictIdle(s, now)
+
+	var b *keyedBucket[K]
+	if e, ok := s.items[key]; ok {
+		b = e.Value.(*keyedBucket[K])
+		b.lastUsed = now
+		s.lru.MoveToFront(e)
+	} else {
+		limit := l.dynLimiter.Limit(key)
+		b = &keyedBucket[K]{
+			key:      key,
+			lim:      rate.NewLimiter(limit, defaultBurst(limit)),
+			limit:    limit,
+			resolved: now,
+			lastUsed: now,
+		}
+		s.items[key] = s.lru.PushFront(b)
+		if l.perShard > 0 && len(s.items) > l.perShard {
+			l.remove(s, s.lru.Back())
+		}
+		s.mu.Unlock()
+		return b.lim
+	}
+
+	var changed bool
+	limit := b.limit
+	if now.Sub(b.resolved) >= l.refreshInterval {
+		b.resolved = now
+		if limit = l.dynLimiter.Limit(key); limit != b.limit {
+			b.lim.SetLimitAt(now, limit)
+			b.lim.SetBurstAt(now, defaultBurst(limit))
+			b.limit = limit
+			changed = true
+		}
+	}
+	s.mu.Unlock()
+	if changed {
+		l.dynLimiter.OnChange(key, limit)
+	}
+	return b.lim
+}
+
+// evictIdle removes the buckets unused for the idle TTL, oldest first. It
+// must be called with s.mu held.
+func (l *KeyedLimiter[K]) evictIdle(s *keyedShard[K], now time.Time) {
+	if l.cfg.idleTTL <= 0 {
+		return
+	}
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		if now.Sub(e.Value.(*keyedBucket[K]).lastUsed) < l.cfg.idleTTL {
+			return
+		}
+		l.remove(s, e)
+	}
+}
+
+func (l *KeyedLimiter[K]) remove(s *keyedShard[K], e *list.Element) {
+	delete(s.items, e.Value.(*keyedBucket[K]).key)
+	s.lru.Remove(e)
+}
@@ -0,0 +1,138 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+func TestKeyedLimiter(t *testing.T) {
+	suite.Run(t, new(TestSuiteKeyedLimiter))
+}
+
+type TestSuiteKeyedLimiter struct {
+	suite.Suite
+}
+
+func (s *TestSuiteKeyedLimiter) TestPerKeyBuckets() {
+	l := NewKeyedLimiter("tenants", time.Minute, KeyedLimitFunc[string](func(key string) rate.Limit {
+		if key == "premium" {
+			return 5
+		}
+		return 2
+	}))
+
+	now := time.Now()
+	count := func(key string) int {
+		n := 0
+		for range 10 {
+			if l.AllowN(key, now, 1) {
+				n++
+			}
+		}
+		return n
+	}
+	s.Equal(2, count("free"))
+	s.Equal(2, count("other"))
+	s.Equal(5, count("premium"))
+	s.Equal(rate.Limit(5), l.Limit("premium"))
+	s.Equal(3, l.Len())
+}
+
+func (s *TestSuiteKeyedLimiter) TestReserveAndWait() {
+	l := NewKeyedLimiter("ips", time.Minute, KeyedLimitFunc[string](func(string) rate.Limit { return 10 }))
+
+	now := time.Now()
+	s.True(l.AllowN("ip", now, 10))
+	r := l.ReserveN("ip", now, 1)
+	s.True(r.OK())
+	s.Equal(100*time.Millisecond, r.DelayFrom(now))
+	r.CancelAt(now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.NoError(l.Wait(ctx, "other"))
+	s.Error(l.WaitN(ctx, "other", 11), "n exceeds burst")
+}
+
+func (s *TestSuiteKeyedLimiter) TestRefresh() {
+	var mu sync.Mutex
+	limits := map[string]rate.Limit{"k": 1}
+	var changes []rate.Limit
+	l := NewKeyedLimiter("refresh", time.Second, &keyedLimits{
+		limit: func(key string) rate.Limit {
+			mu.Lock()
+			defer mu.Unlock()
+			return limits[key]
+		},
+		onChange: func(_ string, l rate.Limit) { changes = append(changes, l) },
+	})
+
+	now := time.Now()
+	s.True(l.AllowN("k", now, 1))
+	mu.Lock()
+	limits["k"] = 4
+	mu.Unlock()
+	s.False(l.AllowN("k", now.Add(100*time.Millisecond), 1), "limit is cached until the refresh interval")
+
+	later := now.Add(time.Second)
+	s.True(l.AllowN("k", later, 1))
+	s.Equal(rate.Limit(4), l.Limit("k"))
+	s.Equal([]rate.Limit{4}, changes)
+}
+
+func (s *TestSuiteKeyedLimiter) TestLRUEviction() {
+	l := NewKeyedLimiter("lru", time.Minute, KeyedLimitFunc[int](func(int) rate.Limit { return 1 }),
+		WithShards(1), WithMaxKeys(2))
+
+	now := time.Now()
+	s.True(l.AllowN(1, now, 1))
+	s.True(l.AllowN(2, now, 1))
+	s.False(l.AllowN(1, now, 1), "touch key 1")
+	s.True(l.AllowN(3, now, 1), "evicts key 2")
+	s.Equal(2, l.Len())
+
+	s.False(l.AllowN(1, now, 1), "key 1 was kept")
+	s.True(l.AllowN(2, now, 1), "key 2 starts over")
+}
+
+func (s *TestSuiteKeyedLimiter) TestIdleEviction() {
+	l := NewKeyedLimiter("ttl", time.Minute, KeyedLimitFunc[int](func(int) rate.Limit { return 1 }),
+		WithShards(1), WithIdleTTL(time.Minute))
+
+	now := time.Now()
+	for key := range 10 {
+		l.AllowN(key, now, 1)
+	}
+	s.Equal(10, l.Len())
+	l.AllowN(0, now.Add(30*time.Second), 1)
+	l.AllowN(100, now.Add(80*time.Second), 1)
+	s.Equal(2, l.Len(), "only recently used keys are kept")
+}
+
+func (s *TestSuiteKeyedLimiter) TestConcurrentUse() {
+	l := NewKeyedLimiter("concurrent", time.Millisecond, KeyedLimitFunc[int](func(int) rate.Limit { return 100 }),
+		WithMaxKeys(64))
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 1000 {
+				l.Allow(g*1000 + i%128)
+			}
+		})
+	}
+	wg.Wait()
+	s.LessOrEqual(l.Len(), 64)
+}
+
+type keyedLimits struct {
+	limit    func(string) rate.Limit
+	onChange func(string, rate.Limit)
+}
+
+func (l *keyedLimits) Limit(key string) rate.Limit           { return l.limit(key) }
+func (l *keyedLimits) OnChange(key string, limit rate.Limit) { l.onChange(key, limit) }
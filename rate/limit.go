@@ -1,6 +1,8 @@
 package rate
 
 import (
+	"time"
+
 	"github.com/yeluyang/gopkg/routine"
 	"golang.org/x/time/rate"
 )
@@ -24,20 +26,37 @@ func newDynamicLimit(
 	return &dynamicLimiter{limit: limit, onChange: onChange}
 }
 
+type dynamicSetting struct {
+	setting  func() Setting
+	onChange func(old, new Setting)
+}
+
+func (s *dynamicSetting) Setting() Setting { return s.setting() }
+func (s *dynamicSetting) OnChange(old, new Setting) {
+	if s.onChange != nil {
+		s.onChange(old, new)
+	}
+}
+
+// limitOnly adapts a DynamicLimit, deriving the burst from the limit.
+type limitOnly struct{ DynamicLimit }
+
+func (l limitOnly) Setting() Setting {
+	limit := l.Limit()
+	return Setting{Limit: limit, Burst: defaultBurst(limit)}
+}
+func (l limitOnly) OnChange(_, new Setting) { l.DynamicLimit.OnChange(new.Limit) }
+
 func (l *Limiter) asyncRun() { routine.Go(func() { l.run() }) }
 func (l *Limiter) run() {
-	for {
-		select {
-		case <-l.stop:
-			return
-		case <-l.ticker.C:
-			curLimit := l.dynLimiter.Limit()
-			if curLimit != l.lastLimit {
-				l.Limiter.SetLimit(curLimit)
-				l.Limiter.SetBurst(max(int(curLimit), 1))
-				l.lastLimit = curLimit
-				l.dynLimiter.OnChange(curLimit)
-			}
-		}
+	var tick <-chan time.Time
+	if l.ticker != nil {
+		tick = l.ticker.C
 	}
+	refresh(l.stop, tick, l.dynLimiter, l.last, func(cur Setting) {
+		// rate.Limiter locks for each call, so the two are not atomic
+		now := time.Now()
+		l.Limiter.SetLimitAt(now, cur.Limit)
+		l.Limiter.SetBurstAt(now, cur.Burst)
+	})
 }
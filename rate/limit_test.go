@@ -1,6 +1,8 @@
 package rate
 
 import (
+	"math"
+	"sync"
 	"testing"
 	"time"
 
@@ -91,3 +93,58 @@ func (s *TestSuiteDynamicRatelimit) TestLimitChangeToZero() {
 	s.Require().Zero(rl.Limit())
 	s.Require().Equal(1, rl.Burst())
 }
+
+func (s *TestSuiteDynamicRatelimit) TestSubOneLimit() {
+	rl := NewDynamicLimiter("test", time.Hour, func() rate.Limit { return 0.5 }, nil)
+	defer rl.Close()
+	s.Require().Equal(1, rl.Burst())
+
+	now := time.Now()
+	s.Require().True(rl.AllowN(now, 1))
+	s.Require().False(rl.AllowN(now.Add(time.Second), 1))
+	s.Require().True(rl.AllowN(now.Add(2*time.Second), 1))
+}
+
+func (s *TestSuiteDynamicRatelimit) TestInfLimit() {
+	rl := NewDynamicLimiter("test", time.Hour, func() rate.Limit { return rate.Inf }, nil)
+	defer rl.Close()
+	s.Require().Equal(math.MaxInt, rl.Burst())
+	s.Require().True(rl.AllowN(time.Now(), 1000000))
+}
+
+func (s *TestSuiteDynamicRatelimit) TestConfigurableBurst() {
+	var mu sync.Mutex
+	setting := Setting{Limit: 0.5, Burst: 3}
+	changes := make(chan [2]Setting, 1)
+	rl := NewDynamicBurstLimiter(
+		"test",
+		10*time.Millisecond,
+		func() Setting {
+			mu.Lock()
+			defer mu.Unlock()
+			return setting
+		},
+		func(old, new Setting) { changes <- [2]Setting{old, new} },
+	)
+	defer rl.Close()
+
+	s.Require().Equal(rate.Limit(0.5), rl.Limit())
+	s.Require().Equal(3, rl.Burst())
+	now := time.Now()
+	s.Require().True(rl.AllowN(now, 3))
+	s.Require().False(rl.AllowN(now, 1))
+
+	mu.Lock()
+	setting = Setting{Limit: rate.Inf, Burst: 0}
+	mu.Unlock()
+	s.Require().Equal([2]Setting{{Limit: 0.5, Burst: 3}, {Limit: rate.Inf, Burst: 0}}, <-changes)
+	s.Require().Equal(rate.Inf, rl.Limit())
+	s.Require().Equal(0, rl.Burst())
+	s.Require().True(rl.AllowN(time.Now(), 100))
+
+	mu.Lock()
+	setting = Setting{Limit: rate.Inf, Burst: 5}
+	mu.Unlock()
+	s.Require().Equal([2]Setting{{Limit: rate.Inf, Burst: 0}, {Limit: rate.Inf, Burst: 5}}, <-changes,
+		"a burst change alone is reported")
+}
@@ -1,6 +1,7 @@
 package rate
 
 import (
+	"math"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -15,15 +16,38 @@ func NewDynamicLimiter(
 	return NewDynamicLimiter2(name, refreshInterval, newDynamicLimit(limit, onChangeLimit))
 }
 
+// NewDynamicLimiter2 is NewDynamicLimiter with the limit given by dynLimiter.
+// The burst follows the limit: max(int(limit), 1), or unbounded for rate.Inf.
+// If dynLimiter is a LimitWatcher, pushed limits apply immediately.
 func NewDynamicLimiter2(name string, refreshInterval time.Duration, dynLimiter DynamicLimit) *Limiter {
+	return NewDynamicBurstLimiter2(name, refreshInterval, limitOnly{dynLimiter})
+}
+
+// NewDynamicBurstLimiter returns a Limiter whose limit and burst are fetched
+// together from setting every refreshInterval. onChange, if not nil, is
+// called with the old and new setting after either changed.
+func NewDynamicBurstLimiter(
+	name string,
+	refreshInterval time.Duration,
+	setting func() Setting,
+	onChange func(old, new Setting),
+) *Limiter {
+	return NewDynamicBurstLimiter2(name, refreshInterval, &dynamicSetting{setting: setting, onChange: onChange})
+}
+
+// NewDynamicBurstLimiter2 is NewDynamicBurstLimiter with the setting given by
+// dynLimiter. If dynLimiter is a SettingWatcher, pushed settings apply
+// immediately; a refreshInterval of zero then disables polling. Otherwise it
+// panics if refreshInterval is not positive, as time.NewTicker.
+func NewDynamicBurstLimiter2(name string, refreshInterval time.Duration, dynLimiter DynamicLimitBurst) *Limiter {
 	l := &Limiter{
 		name:       name,
 		stop:       make(chan struct{}),
-		ticker:     time.NewTicker(refreshInterval),
-		lastLimit:  dynLimiter.Limit(),
+		last:       dynLimiter.Setting(),
 		dynLimiter: dynLimiter,
+		ticker:     newRefreshTicker(refreshInterval, dynLimiter),
 	}
-	l.Limiter = rate.NewLimiter(l.lastLimit, max(int(l.lastLimit), 1))
+	l.Limiter = rate.NewLimiter(l.last.Limit, l.last.Burst)
 	l.asyncRun()
 	return l
 }
@@ -33,17 +57,42 @@ type DynamicLimit interface {
 	OnChange(rate.Limit)
 }
 
+// Setting is a limit with its burst, see golang.org/x/time/rate.NewLimiter.
+type Setting struct {
+	Limit rate.Limit
+	Burst int
+}
+
+// DynamicLimitBurst is a DynamicLimit that also controls the burst.
+// Setting returns both at once, so a change is never read half done. They
+// are then applied back to back but not atomically: a request racing with
+// the change may see the new limit with the old burst.
+type DynamicLimitBurst interface {
+	Setting() Setting
+	OnChange(old, new Setting)
+}
+
+// defaultBurst is the burst of limiters configured with a limit only.
+func defaultBurst(limit rate.Limit) int {
+	if limit == rate.Inf {
+		return math.MaxInt
+	}
+	return max(int(limit), 1)
+}
+
 type Limiter struct {
 	*rate.Limiter
 
 	name       string
 	stop       chan struct{}
 	ticker     *time.Ticker
-	lastLimit  rate.Limit
-	dynLimiter DynamicLimit
+	last       Setting
+	dynLimiter DynamicLimitBurst
 }
 
 func (l *Limiter) Close() {
-	l.ticker.Stop()
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
 	close(l.stop)
 }
@@ -0,0 +1,75 @@
+package rate
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// BenchmarkKeyedLimiter measures Allow under contention with many keys, for
+// the default shard count against a single lock.
+func BenchmarkKeyedLimiter(b *testing.B) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = "tenant-" + strconv.Itoa(i)
+	}
+	limit := KeyedLimitFunc[string](func(string) rate.Limit { return 1000 })
+
+	for _, shards := range []int{1, 32} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			l := NewKeyedLimiter("bench", time.Minute, limit, WithShards(shards))
+			var next atomic.Uint32
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1)) * 7919
+				for pb.Next() {
+					l.Allow(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkKeyedLimiterEviction measures Allow when the key space exceeds
+// WithMaxKeys, so that most calls create a bucket and evict another.
+func BenchmarkKeyedLimiterEviction(b *testing.B) {
+	l := NewKeyedLimiter("bench", time.Minute, KeyedLimitFunc[int](func(int) rate.Limit { return 1000 }),
+		WithMaxKeys(1024))
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Allow(int(next.Add(1)))
+		}
+	})
+}
+
+// BenchmarkMutexMap is the naive baseline: a map of limiters behind one lock.
+func BenchmarkMutexMap(b *testing.B) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = "tenant-" + strconv.Itoa(i)
+	}
+	var mu sync.Mutex
+	limiters := make(map[string]*rate.Limiter)
+	var next atomic.Uint32
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1)) * 7919
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			mu.Lock()
+			lim, ok := limiters[key]
+			if !ok {
+				lim = rate.NewLimiter(1000, 1000)
+				limiters[key] = lim
+			}
+			mu.Unlock()
+			lim.Allow()
+			i++
+		}
+	})
+}
@@ -0,0 +1,392 @@
+package rate
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// tokenBucketScript mirrors takeTokenBucket. KEYS[1] is a hash holding the
+// token count and the last update; ARGV are limit, burst, n and now in
+// microseconds. It returns {allowed, remaining, retry after in µs or -1}.
+// Times are stored with string.format('%d'), since tostring keeps only 14
+// significant digits.
+const tokenBucketScript = `
+local limit = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) / 1e6 * limit)
+  ts = now
+end
+local allowed = 0
+local retry = 0
+if tokens >= n then
+  tokens = tokens - n
+  allowed = 1
+elseif limit > 0 and n <= burst then
+  retry = math.ceil((n - tokens) / limit * 1e6)
+else
+  retry = -1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%d', ts))
+if limit > 0 then
+  redis.call('PEXPIRE', KEYS[1], math.ceil(burst / limit * 1e3) + 1000)
+end
+return {allowed, math.floor(tokens), retry}
+`
+
+// gcraScript mirrors takeGCRA. KEYS[1] holds the theoretical arrival time in
+// whole microseconds; ARGV and the result are as for tokenBucketScript.
+const gcraScript = `
+local limit = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+if limit <= 0 or n > burst then
+  return {0, 0, -1}
+end
+local interval = 1e6 / limit
+local tolerance = interval * burst
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+  tat = now
+end
+local new_tat = math.floor(tat + n * interval)
+local allow_at = new_tat - tolerance
+if now < allow_at then
+  return {0, math.floor((tolerance - (tat - now)) / interval), math.ceil(allow_at - now)}
+end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1e3) + 1)
+return {1, math.floor((tolerance - (new_tat - now)) / interval), 0}
+`
+
+type script struct {
+	src string
+	sha string
+}
+
+func newScript(src string) script {
+	sum := sha1.Sum([]byte(src))
+	return script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+var scripts = map[Algorithm]script{
+	TokenBucket: newScript(tokenBucketScript),
+	GCRA:        newScript(gcraScript),
+}
+
+// RedisError is an error reply of the Redis server.
+type RedisError string
+
+func (e RedisError) Error() string { return "rate: redis: " + string(e) }
+
+func asRedisError(err error) (RedisError, bool) {
+	var rerr RedisError
+	ok := errors.As(err, &rerr)
+	return rerr, ok
+}
+
+// RedisOption configures a RedisStore.
+type RedisOption func(*redisConfig)
+
+type redisConfig struct {
+	password    string
+	db          int
+	prefix      string
+	poolSize    int
+	dialTimeout time.Duration
+}
+
+// WithRedisPassword authenticates new connections with AUTH.
+func WithRedisPassword(password string) RedisOption {
+	return func(c *redisConfig) {
+		c.password = password
+	}
+}
+
+// WithRedisDB selects the database of new connections. Defaults to 0.
+func WithRedisDB(db int) RedisOption {
+	return func(c *redisConfig) {
+		c.db = db
+	}
+}
+
+// WithKeyPrefix sets the prefix of every key. Defaults to "rate:".
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(c *redisConfig) {
+		c.prefix = prefix
+	}
+}
+
+// WithRedisPoolSize sets the number of idle connections kept for reuse.
+// Defaults to 8.
+func WithRedisPoolSize(n int) RedisOption {
+	return func(c *redisConfig) {
+		c.poolSize = n
+	}
+}
+
+// WithDialTimeout bounds connecting to the server, on top of the context
+// deadline. Defaults to one second.
+func WithDialTimeout(d time.Duration) RedisOption {
+	return func(c *redisConfig) {
+		c.dialTimeout = d
+	}
+}
+
+// RedisStore is a Store backed by a server speaking the Redis protocol.
+// Each Take runs one Lua script, so buckets are updated atomically even when
+// shared by many replicas.
+type RedisStore struct {
+	addr string
+	cfg  redisConfig
+	idle chan *redisConn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedisStore returns a store for the server at addr. Connections are
+// opened lazily.
+func NewRedisStore(addr string, options ...RedisOption) *RedisStore {
+	cfg := redisConfig{prefix: "rate:", poolSize: 8, dialTimeout: time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	return &RedisStore{addr: addr, cfg: cfg, idle: make(chan *redisConn, max(cfg.poolSize, 0))}
+}
+
+func (s *RedisStore) Take(ctx context.Context, alg Algorithm, req Request) (Result, error) {
+	sc, ok := scripts[alg]
+	if !ok {
+		return Result{}, fmt.Errorf("rate: unknown algorithm %d", alg)
+	}
+	if req.Limit == rate.Inf {
+		// as in take; the scripts cannot parse an infinite limit
+		return Result{Allowed: true, Remaining: req.Burst}, nil
+	}
+	key := s.cfg.prefix + alg.String() + ":" + req.Key
+	args := []string{
+		strconv.FormatFloat(float64(req.Limit), 'g', -1, 64),
+		strconv.Itoa(req.Burst),
+		strconv.Itoa(req.N),
+		strconv.FormatInt(req.Now.UnixMicro(), 10),
+	}
+
+	reply, err := s.do(ctx, append([]string{"EVALSHA", sc.sha, "1", key}, args...))
+	if rerr, ok := asRedisError(err); ok && strings.HasPrefix(string(rerr), "NOSCRIPT") {
+		reply, err = s.do(ctx, append([]string{"EVAL", sc.src, "1", key}, args...))
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	vals, ok := reply.([]any)
+	if !ok || len(vals) != 3 {
+		return Result{}, fmt.Errorf("rate: redis: unexpected script reply %v", reply)
+	}
+	var ints [3]int64
+	for i, v := range vals {
+		if ints[i], ok = v.(int64); !ok {
+			return Result{}, fmt.Errorf("rate: redis: unexpected script reply %v", reply)
+		}
+	}
+	res := Result{Allowed: ints[0] == 1, Remaining: int(ints[1])}
+	if ints[2] < 0 {
+		res.RetryAfter = rate.InfDuration
+	} else {
+		res.RetryAfter = time.Duration(ints[2]) * time.Microsecond
+	}
+	return res, nil
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// returned.
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.idle)
+	for c := range s.idle {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// do sends one command and reads its reply. A connection is reused only
+// after a complete exchange; on I/O errors it is closed.
+func (s *RedisStore) do(ctx context.Context, args []string) (any, error) {
+	c, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(ctx, args)
+	if _, ok := asRedisError(err); err != nil && !ok {
+		c.conn.Close()
+		return nil, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, errors.New("rate: redis store is closed")
+	}
+	select {
+	case c, ok := <-s.idle:
+		if ok {
+			return c, nil
+		}
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: s.cfg.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("rate: redis: %w", err)
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if s.cfg.password != "" {
+		if _, err := c.do(ctx, []string{"AUTH", s.cfg.password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.cfg.db != 0 {
+		if _, err := c.do(ctx, []string{"SELECT", strconv.Itoa(s.cfg.db)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *RedisStore) put(c *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		c.conn.Close()
+		return
+	}
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *redisConn) do(ctx context.Context, args []string) (any, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("rate: redis: %w", err)
+	}
+	if _, err := c.conn.Write(appendCommand(nil, args)); err != nil {
+		return nil, fmt.Errorf("rate: redis: %w", err)
+	}
+	reply, err := readReply(c.r)
+	if err != nil {
+		if _, ok := asRedisError(err); !ok {
+			err = fmt.Errorf("rate: redis: %w", err)
+		}
+	}
+	return reply, err
+}
+
+// appendCommand encodes args as a RESP array of bulk strings.
+func appendCommand(buf []byte, args []string) []byte {
+	buf = fmt.Appendf(buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return buf
+}
+
+// readReply decodes one RESP reply: simple and bulk strings as string, nil
+// bulk strings and arrays as nil, integers as int64, arrays as []any and
+// errors as RedisError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		vals := make([]any, n)
+		for i := range vals {
+			v, err := readReply(r)
+			if _, ok := asRedisError(err); err != nil && !ok {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", fmt.Errorf("malformed reply line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,459 @@
+package rate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	lua "github.com/yuin/gopher-lua"
+	"golang.org/x/time/rate"
+)
+
+func TestRedisStore(t *testing.T) {
+	suite.Run(t, new(TestSuiteRedisStore))
+}
+
+type TestSuiteRedisStore struct {
+	suite.Suite
+}
+
+func (s *TestSuiteRedisStore) TestTake() {
+	srv := newFakeRedis(s.T())
+	store := NewRedisStore(srv.addr())
+	defer store.Close()
+
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		now := time.Unix(1700000000, 0)
+		req := Request{Key: "k", Limit: 10, Burst: 2, N: 1, Now: now}
+		for range 2 {
+			res, err := store.Take(context.Background(), alg, req)
+			s.Require().NoError(err)
+			s.True(res.Allowed)
+		}
+		res, err := store.Take(context.Background(), alg, req)
+		s.Require().NoError(err)
+		s.False(res.Allowed)
+		s.Equal(100*time.Millisecond, res.RetryAfter)
+
+		req.N = 3
+		res, err = store.Take(context.Background(), alg, req)
+		s.Require().NoError(err)
+		s.Equal(rate.InfDuration, res.RetryAfter)
+	}
+	s.Equal([]string{"rate:gcra:k", "rate:token-bucket:k"}, srv.keys())
+}
+
+func (s *TestSuiteRedisStore) TestMicrosecondPrecision() {
+	srv := newFakeRedis(s.T())
+	store := NewRedisStore(srv.addr())
+	defer store.Close()
+
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		now := time.UnixMicro(1700000000123456)
+		req := Request{Key: "k", Limit: 10, Burst: 1, N: 1, Now: now}
+		res, err := store.Take(context.Background(), alg, req)
+		s.Require().NoError(err)
+		s.Require().True(res.Allowed)
+
+		req.Now = now.Add(50 * time.Millisecond)
+		res, err = store.Take(context.Background(), alg, req)
+		s.Require().NoError(err)
+		s.False(res.Allowed)
+		s.Equal(50*time.Millisecond, res.RetryAfter, alg.String())
+	}
+}
+
+func (s *TestSuiteRedisStore) TestInfiniteLimit() {
+	srv := newFakeRedis(s.T())
+	store := NewRedisStore(srv.addr())
+	defer store.Close()
+
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		res, err := store.Take(context.Background(), alg, Request{Key: "k", Limit: rate.Inf, Burst: 1, N: 5, Now: time.Now()})
+		s.Require().NoError(err)
+		s.Equal(Result{Allowed: true, Remaining: 1}, res)
+	}
+	s.Empty(srv.commands(), "an infinite limit needs no round trip")
+}
+
+func (s *TestSuiteRedisStore) TestLoadsScriptOnce() {
+	srv := newFakeRedis(s.T())
+	store := NewRedisStore(srv.addr(), WithRedisPoolSize(1))
+	defer store.Close()
+
+	for range 3 {
+		_, err := store.Take(context.Background(), GCRA, Request{Key: "k", Limit: 1, Burst: 1, N: 1, Now: time.Now()})
+		s.Require().NoError(err)
+	}
+	s.Equal([]string{"EVALSHA", "EVAL", "EVALSHA", "EVALSHA"}, srv.commands())
+	s.Equal(1, srv.connections())
+}
+
+func (s *TestSuiteRedisStore) TestAuthAndSelect() {
+	srv := newFakeRedis(s.T())
+	srv.setPassword("secret")
+
+	store := NewRedisStore(srv.addr())
+	_, err := store.Take(context.Background(), GCRA, Request{Key: "k", Limit: 1, Burst: 1, N: 1, Now: time.Now()})
+	var rerr RedisError
+	s.Require().ErrorAs(err, &rerr)
+	s.Contains(string(rerr), "NOAUTH")
+	store.Close()
+
+	store = NewRedisStore(srv.addr(), WithRedisPassword("secret"), WithRedisDB(2), WithKeyPrefix("app:"))
+	defer store.Close()
+	_, err = store.Take(context.Background(), GCRA, Request{Key: "k", Limit: 1, Burst: 1, N: 1, Now: time.Now()})
+	s.Require().NoError(err)
+	s.Equal([]string{"app:gcra:k"}, srv.keys())
+}
+
+func (s *TestSuiteRedisStore) TestUnreachable() {
+	srv := newFakeRedis(s.T())
+	addr := srv.addr()
+	srv.close()
+
+	store := NewRedisStore(addr, WithDialTimeout(100*time.Millisecond))
+	defer store.Close()
+	_, err := store.Take(context.Background(), GCRA, Request{Key: "k", Limit: 1, Burst: 1, N: 1, Now: time.Now()})
+	s.Error(err)
+}
+
+func (s *TestSuiteRedisStore) TestReadReply() {
+	r := bufio.NewReader(strings.NewReader("*4\r\n:1\r\n$3\r\nabc\r\n$-1\r\n+OK\r\n-ERR boom\r\n"))
+	v, err := readReply(r)
+	s.Require().NoError(err)
+	s.Equal([]any{int64(1), "abc", nil, "OK"}, v)
+	_, err = readReply(r)
+	s.Equal(RedisError("ERR boom"), err)
+}
+
+// fakeRedis is a local stand-in for a Redis server, in the spirit of
+// miniredis. It runs the scripts it is sent in a Lua 5.1 interpreter, with
+// the few commands they call.
+type fakeRedis struct {
+	t    *testing.T
+	ln   net.Listener
+	done chan struct{}
+
+	mu       sync.Mutex
+	password string
+	conns    int
+	cmds     []string
+	scripts  map[string]string
+	data     map[string]*fakeKey
+	wg       sync.WaitGroup
+}
+
+// fakeKey is a string or a hash.
+type fakeKey struct {
+	str     string
+	hash    map[string]string
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeRedis{ln: ln, done: make(chan struct{}), scripts: make(map[string]string), data: make(map[string]*fakeKey)}
+	srv.wg.Add(1)
+	go srv.serve()
+	t.Cleanup(srv.close)
+	return srv
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+func (f *fakeRedis) close() {
+	select {
+	case <-f.done:
+	default:
+		close(f.done)
+		f.ln.Close()
+	}
+	f.wg.Wait()
+}
+
+func (f *fakeRedis) setPassword(password string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.password = password
+}
+
+func (f *fakeRedis) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.cmds...)
+}
+
+func (f *fakeRedis) connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+func (f *fakeRedis) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (f *fakeRedis) serve() {
+	defer f.wg.Done()
+	var conns sync.WaitGroup
+	defer conns.Wait()
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		f.mu.Unlock()
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+			go func() {
+				// unblock the reader when the server closes
+				<-f.done
+				conn.Close()
+			}()
+			f.handle(conn)
+		}()
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	f.mu.Lock()
+	password := f.password
+	f.mu.Unlock()
+	authed := password == ""
+	for {
+		v, err := readReply(r)
+		if err != nil {
+			return
+		}
+		args, _ := v.([]any)
+		if len(args) == 0 {
+			return
+		}
+		cmd := make([]string, len(args))
+		for i, a := range args {
+			cmd[i], _ = a.(string)
+		}
+
+		var reply string
+		switch name := strings.ToUpper(cmd[0]); {
+		case name == "AUTH":
+			authed = len(cmd) == 2 && cmd[1] == password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case name == "SELECT":
+			reply = "+OK\r\n"
+		case name == "EVALSHA" || name == "EVAL":
+			reply = f.eval(name, cmd[1:])
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd[0])
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) eval(name string, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cmds = append(f.cmds, name)
+
+	if len(args) < 2 {
+		return "-ERR wrong number of arguments\r\n"
+	}
+	src, ok := f.scripts[args[0]]
+	if name == "EVAL" {
+		src = args[0]
+		f.scripts[newScript(src).sha] = src
+	} else if !ok {
+		return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 0 || numKeys > len(args)-2 {
+		return "-ERR Number of keys can't be greater than number of args\r\n"
+	}
+
+	L := lua.NewState()
+	defer L.Close()
+	L.SetGlobal("KEYS", luaStrings(L, args[2:2+numKeys]))
+	L.SetGlobal("ARGV", luaStrings(L, args[2+numKeys:]))
+	// tostring formats numbers with 14 significant digits, as in Lua 5.1
+	tostring := L.GetGlobal("tostring")
+	L.SetGlobal("tostring", L.NewFunction(func(L *lua.LState) int {
+		if n, ok := L.Get(1).(lua.LNumber); ok {
+			L.Push(lua.LString(fmt.Sprintf("%.14g", float64(n))))
+			return 1
+		}
+		L.Push(tostring)
+		L.Push(L.Get(1))
+		L.Call(1, 1)
+		return 1
+	}))
+	redis := L.NewTable()
+	redis.RawSetString("call", L.NewFunction(f.call))
+	L.SetGlobal("redis", redis)
+	if err := L.DoString(src); err != nil {
+		return fmt.Sprintf("-ERR %s\r\n", strings.ReplaceAll(err.Error(), "\n", " "))
+	}
+	return string(appendLuaReply(nil, L.Get(-1)))
+}
+
+// call implements redis.call for the commands of the scripts. It must be
+// called with f.mu held.
+func (f *fakeRedis) call(L *lua.LState) int {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		switch v := L.Get(i + 1).(type) {
+		case lua.LNumber:
+			args[i] = strconv.FormatFloat(float64(v), 'f', -1, 64)
+		case lua.LString:
+			args[i] = string(v)
+		default:
+			L.RaiseError("redis.call: argument %d is a %s", i+1, v.Type())
+		}
+	}
+	if len(args) < 2 {
+		L.RaiseError("redis.call: wrong number of arguments")
+	}
+	cmd, key := strings.ToUpper(args[0]), args[1]
+	k := f.data[key]
+	if k != nil && !k.expires.IsZero() && !time.Now().Before(k.expires) {
+		delete(f.data, key)
+		k = nil
+	}
+
+	switch {
+	case cmd == "GET" && len(args) == 2:
+		if k == nil || k.hash != nil {
+			L.Push(lua.LFalse)
+		} else {
+			L.Push(lua.LString(k.str))
+		}
+	case cmd == "SET" && len(args) == 3:
+		f.data[key] = &fakeKey{str: args[2]}
+		L.Push(luaStatus(L, "OK"))
+	case cmd == "SET" && len(args) == 5 && strings.ToUpper(args[3]) == "PX":
+		ms, err := strconv.ParseInt(args[4], 10, 64)
+		if err != nil || ms <= 0 {
+			L.RaiseError("ERR invalid expire time in 'set' command")
+		}
+		f.data[key] = &fakeKey{str: args[2], expires: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+		L.Push(luaStatus(L, "OK"))
+	case cmd == "HMGET" && len(args) > 2:
+		t := L.NewTable()
+		for _, field := range args[2:] {
+			if v, ok := k.field(field); ok {
+				t.Append(lua.LString(v))
+			} else {
+				t.Append(lua.LFalse)
+			}
+		}
+		L.Push(t)
+	case cmd == "HSET" && len(args) > 2 && len(args)%2 == 0:
+		if k == nil {
+			k = &fakeKey{hash: make(map[string]string)}
+			f.data[key] = k
+		}
+		added := 0
+		for i := 2; i < len(args); i += 2 {
+			if _, ok := k.hash[args[i]]; !ok {
+				added++
+			}
+			k.hash[args[i]] = args[i+1]
+		}
+		L.Push(lua.LNumber(added))
+	case cmd == "PEXPIRE" && len(args) == 3:
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			L.RaiseError("ERR value is not an integer or out of range")
+		}
+		if k == nil {
+			L.Push(lua.LNumber(0))
+			break
+		}
+		k.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		L.Push(lua.LNumber(1))
+	default:
+		L.RaiseError("ERR unknown command or arguments %q", args)
+	}
+	return 1
+}
+
+func (k *fakeKey) field(name string) (string, bool) {
+	if k == nil {
+		return "", false
+	}
+	v, ok := k.hash[name]
+	return v, ok
+}
+
+func luaStrings(L *lua.LState, vals []string) *lua.LTable {
+	t := L.NewTable()
+	for _, v := range vals {
+		t.Append(lua.LString(v))
+	}
+	return t
+}
+
+func luaStatus(L *lua.LState, status string) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("ok", lua.LString(status))
+	return t
+}
+
+// appendLuaReply encodes a script result as Redis does: numbers are
+// truncated to integers, tables become arrays up to their first nil, false
+// becomes a nil reply and true the integer 1.
+func appendLuaReply(buf []byte, v lua.LValue) []byte {
+	switch v := v.(type) {
+	case lua.LNumber:
+		return fmt.Appendf(buf, ":%d\r\n", int64(v))
+	case lua.LString:
+		return fmt.Appendf(buf, "$%d\r\n%s\r\n", len(v), string(v))
+	case lua.LBool:
+		if v {
+			return append(buf, ":1\r\n"...)
+		}
+		return append(buf, "$-1\r\n"...)
+	case *lua.LTable:
+		if ok := v.RawGetString("ok"); ok != lua.LNil {
+			return fmt.Appendf(buf, "+%s\r\n", ok.String())
+		}
+		n := v.Len()
+		buf = fmt.Appendf(buf, "*%d\r\n", n)
+		for i := 1; i <= n; i++ {
+			buf = appendLuaReply(buf, v.RawGetInt(i))
+		}
+		return buf
+	default:
+		return append(buf, "$-1\r\n"...)
+	}
+}
@@ -0,0 +1,175 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Algorithm selects how a Store accounts for requests.
+type Algorithm int
+
+const (
+	// TokenBucket refills burst tokens at the limit rate, like
+	// golang.org/x/time/rate. It stores a token count and a timestamp.
+	TokenBucket Algorithm = iota
+	// GCRA is the generic cell rate algorithm. It admits the same traffic as
+	// TokenBucket but stores a single theoretical arrival time.
+	GCRA
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token-bucket"
+	case GCRA:
+		return "gcra"
+	default:
+		return "unknown"
+	}
+}
+
+// Request asks a Store for N tokens of the bucket at Key.
+type Request struct {
+	Key   string
+	Limit rate.Limit
+	Burst int
+	N     int
+	// Now is the time of the request. Replicas sharing a store should have
+	// synchronized clocks.
+	Now time.Time
+}
+
+// Result is the outcome of Store.Take.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of tokens left after the request.
+	Remaining int
+	// RetryAfter is how long to wait before the request could be allowed,
+	// zero if allowed, or rate.InfDuration if it never will be.
+	RetryAfter time.Duration
+}
+
+// Store keeps rate limiting state shared by every replica of a service.
+// Take must check and update the bucket atomically.
+type Store interface {
+	Take(ctx context.Context, alg Algorithm, req Request) (Result, error)
+}
+
+// bucket is the state of a key: token count and last update for
+// TokenBucket, theoretical arrival time for GCRA. Times are in microseconds
+// since the Unix epoch, as in the Redis scripts.
+type bucket struct {
+	tokens float64
+	ts     float64
+	tat    float64
+	// expires is when the state can be dropped, in microseconds.
+	expires float64
+}
+
+// take applies alg to b, which is nil for a new key, and returns the new
+// state or nil if it is unchanged. It mirrors the Lua scripts of RedisStore.
+func take(alg Algorithm, b *bucket, req Request) (Result, *bucket) {
+	if req.Limit == rate.Inf {
+		return Result{Allowed: true, Remaining: req.Burst}, nil
+	}
+	limit, burst, n := float64(req.Limit), float64(req.Burst), float64(req.N)
+	now := float64(req.Now.UnixMicro())
+	if alg == GCRA {
+		return takeGCRA(b, limit, burst, n, now)
+	}
+	return takeTokenBucket(b, limit, burst, n, now)
+}
+
+func takeTokenBucket(b *bucket, limit, burst, n, now float64) (Result, *bucket) {
+	tokens, ts := burst, now
+	if b != nil {
+		tokens, ts = b.tokens, b.ts
+	}
+	if now > ts {
+		tokens = math.Min(burst, tokens+(now-ts)/1e6*limit)
+		ts = now
+	}
+
+	res := Result{}
+	switch {
+	case tokens >= n:
+		tokens -= n
+		res.Allowed = true
+	case limit > 0 && n <= burst:
+		res.RetryAfter = time.Duration(math.Ceil((n-tokens)/limit*1e6)) * time.Microsecond
+	default:
+		res.RetryAfter = rate.InfDuration
+	}
+	res.Remaining = int(tokens)
+
+	next := &bucket{tokens: tokens, ts: ts, expires: math.Inf(1)}
+	if limit > 0 {
+		next.expires = now + math.Ceil(burst/limit*1e3)*1e3 + 1e6
+	}
+	return res, next
+}
+
+func takeGCRA(b *bucket, limit, burst, n, now float64) (Result, *bucket) {
+	if limit <= 0 || n > burst {
+		return Result{RetryAfter: rate.InfDuration}, nil
+	}
+	interval := 1e6 / limit
+	tolerance := interval * burst
+	tat := now
+	if b != nil && b.tat > now {
+		tat = b.tat
+	}
+	// whole microseconds, as stored by gcraScript
+	newTat := math.Floor(tat + n*interval)
+	if allowAt := newTat - tolerance; now < allowAt {
+		return Result{
+			Remaining:  int((tolerance - (tat - now)) / interval),
+			RetryAfter: time.Duration(math.Ceil(allowAt-now)) * time.Microsecond,
+		}, nil
+	}
+	next := &bucket{tat: newTat, expires: now + (math.Ceil((newTat-now)/1e3)+1)*1e3}
+	return Result{Allowed: true, Remaining: int((tolerance - (newTat - now)) / interval)}, next
+}
+
+// MemoryStore is a process-local Store, for tests and single replica
+// deployments. Idle keys are dropped once their bucket is full again.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, alg Algorithm, req Request) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := float64(req.Now.UnixMicro())
+	key := alg.String() + ":" + req.Key
+	b := s.buckets[key]
+	if b != nil && b.expires <= now {
+		b = nil
+	}
+	res, next := take(alg, b, req)
+	if next != nil {
+		s.buckets[key] = next
+	}
+
+	// sweep expired keys now and then so idle keys do not pile up
+	if s.takes++; s.takes >= 1024 {
+		s.takes = 0
+		for k, b := range s.buckets {
+			if b.expires <= now {
+				delete(s.buckets, k)
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,107 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+func TestStore(t *testing.T) {
+	suite.Run(t, new(TestSuiteStore))
+}
+
+type TestSuiteStore struct {
+	suite.Suite
+}
+
+func (s *TestSuiteStore) TestAlgorithms() {
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		s.Run(alg.String(), func() {
+			store := NewMemoryStore()
+			now := time.Unix(1700000000, 0)
+			req := Request{Key: "k", Limit: 10, Burst: 5, N: 1, Now: now}
+
+			for i := range 5 {
+				res, err := store.Take(context.Background(), alg, req)
+				s.Require().NoError(err)
+				s.True(res.Allowed, "request %d", i)
+				s.Equal(4-i, res.Remaining)
+			}
+			res, err := store.Take(context.Background(), alg, req)
+			s.Require().NoError(err)
+			s.False(res.Allowed)
+			s.Equal(100*time.Millisecond, res.RetryAfter)
+
+			req.Now = now.Add(100 * time.Millisecond)
+			res, err = store.Take(context.Background(), alg, req)
+			s.Require().NoError(err)
+			s.True(res.Allowed)
+			s.Zero(res.Remaining)
+
+			req.Now = now.Add(time.Hour)
+			req.N = 5
+			res, err = store.Take(context.Background(), alg, req)
+			s.Require().NoError(err)
+			s.True(res.Allowed, "bucket should be full again")
+
+			req.N = 6
+			res, err = store.Take(context.Background(), alg, req)
+			s.Require().NoError(err)
+			s.False(res.Allowed)
+			s.Equal(rate.InfDuration, res.RetryAfter)
+		})
+	}
+}
+
+func (s *TestSuiteStore) TestSpecialLimits() {
+	store := NewMemoryStore()
+	now := time.Now()
+	for _, alg := range []Algorithm{TokenBucket, GCRA} {
+		res, err := store.Take(context.Background(), alg, Request{Key: "inf", Limit: rate.Inf, Burst: 1, N: 100, Now: now})
+		s.Require().NoError(err)
+		s.True(res.Allowed)
+	}
+
+	res, err := store.Take(context.Background(), TokenBucket, Request{Key: "zero", Limit: 0, Burst: 1, N: 1, Now: now})
+	s.Require().NoError(err)
+	s.True(res.Allowed, "token bucket starts full")
+	res, err = store.Take(context.Background(), TokenBucket, Request{Key: "zero", Limit: 0, Burst: 1, N: 1, Now: now.Add(time.Hour)})
+	s.Require().NoError(err)
+	s.False(res.Allowed)
+	s.Equal(rate.InfDuration, res.RetryAfter)
+
+	res, err = store.Take(context.Background(), GCRA, Request{Key: "zero", Limit: 0, Burst: 1, N: 1, Now: now})
+	s.Require().NoError(err)
+	s.False(res.Allowed)
+}
+
+func (s *TestSuiteStore) TestKeysAreIndependent() {
+	store := NewMemoryStore()
+	now := time.Now()
+	for _, key := range []string{"a", "b"} {
+		res, err := store.Take(context.Background(), GCRA, Request{Key: key, Limit: 1, Burst: 1, N: 1, Now: now})
+		s.Require().NoError(err)
+		s.True(res.Allowed, key)
+	}
+}
+
+func (s *TestSuiteStore) TestExpiredKeysAreSwept() {
+	store := NewMemoryStore()
+	now := time.Now()
+	for i := range 1024 {
+		_, err := store.Take(context.Background(), TokenBucket, Request{Key: string(rune('a' + i%26)), Limit: 100, Burst: 1, N: 1, Now: now})
+		s.Require().NoError(err)
+	}
+	_, err := store.Take(context.Background(), TokenBucket, Request{Key: "late", Limit: 100, Burst: 1, N: 1, Now: now.Add(time.Hour)})
+	s.Require().NoError(err)
+	for i := range 1023 {
+		_, err = store.Take(context.Background(), TokenBucket, Request{Key: "late", Limit: 100, Burst: 1, N: 1, Now: now.Add(time.Hour + time.Duration(i)*time.Millisecond)})
+		s.Require().NoError(err)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	s.Len(store.buckets, 1)
+}
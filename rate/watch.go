@@ -0,0 +1,170 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yeluyang/gopkg/routine"
+	"golang.org/x/time/rate"
+)
+
+// SettingWatcher is implemented by a DynamicLimitBurst that pushes changes.
+// Watch returns a channel receiving each new setting until ctx is done, or
+// nil if the source cannot push. Limiters apply pushed settings immediately
+// and keep polling every refreshInterval as a fallback.
+type SettingWatcher interface {
+	Watch(ctx context.Context) <-chan Setting
+}
+
+// LimitWatcher is the SettingWatcher of a DynamicLimit.
+type LimitWatcher interface {
+	Watch(ctx context.Context) <-chan rate.Limit
+}
+
+func (l limitOnly) Watch(ctx context.Context) <-chan Setting {
+	w, ok := l.DynamicLimit.(LimitWatcher)
+	if !ok {
+		return nil
+	}
+	limits := w.Watch(ctx)
+	if limits == nil {
+		return nil
+	}
+	settings := make(chan Setting)
+	routine.Go(func() {
+		defer close(settings)
+		for {
+			var limit rate.Limit
+			select {
+			case <-ctx.Done():
+				return
+			case limit, ok = <-limits:
+				if !ok {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case settings <- Setting{Limit: limit, Burst: defaultBurst(limit)}:
+			}
+		}
+	})
+	return settings
+}
+
+// newRefreshTicker returns the ticker polling src every interval, or nil if
+// interval is not positive and src pushes its settings. A source that cannot
+// push must be polled, so it panics then, as time.NewTicker.
+func newRefreshTicker(interval time.Duration, src DynamicLimitBurst) *time.Ticker {
+	if interval > 0 {
+		return time.NewTicker(interval)
+	}
+	if !canWatch(src) {
+		panic("rate: non-positive refresh interval for a source that cannot push")
+	}
+	return nil
+}
+
+// canWatch reports whether src pushes its settings.
+func canWatch(src DynamicLimitBurst) bool {
+	if l, ok := src.(limitOnly); ok {
+		_, ok = l.DynamicLimit.(LimitWatcher)
+		return ok
+	}
+	_, ok := src.(SettingWatcher)
+	return ok
+}
+
+// refresh applies the setting of src whenever it changes, then reports the
+// change to src. Settings are polled on tick and, if src is a
+// SettingWatcher, pushed by it, until stop is closed.
+func refresh(stop <-chan struct{}, tick <-chan time.Time, src DynamicLimitBurst, last Setting, apply func(Setting)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var pushed <-chan Setting
+	if w, ok := src.(SettingWatcher); ok {
+		pushed = w.Watch(ctx)
+	}
+
+	update := func(cur Setting) {
+		if cur != last {
+			old := last
+			apply(cur)
+			last = cur
+			src.OnChange(old, cur)
+		}
+	}
+	// catch up with changes made before the watch started
+	update(src.Setting())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			update(src.Setting())
+		case cur, ok := <-pushed:
+			if !ok {
+				pushed = nil
+				continue
+			}
+			update(cur)
+		}
+	}
+}
+
+// AtomicLimit is a DynamicLimitBurst holding its setting in memory, for
+// settings delivered by callbacks, e.g. from a config client. Store pushes
+// the new setting to every watching limiter. Pass it to
+// NewDynamicBurstLimiter2 or NewDistributedBurstLimiter.
+type AtomicLimit struct {
+	mu       sync.Mutex
+	setting  Setting
+	watchers map[chan Setting]struct{}
+}
+
+// NewAtomicLimit returns an AtomicLimit holding setting.
+func NewAtomicLimit(setting Setting) *AtomicLimit {
+	return &AtomicLimit{setting: setting, watchers: make(map[chan Setting]struct{})}
+}
+
+// Store replaces the setting. Watchers lagging behind only get the latest.
+func (a *AtomicLimit) Store(setting Setting) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if setting == a.setting {
+		return
+	}
+	a.setting = setting
+	for ch := range a.watchers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- setting
+	}
+}
+
+// Setting returns the current setting.
+func (a *AtomicLimit) Setting() Setting {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.setting
+}
+
+func (a *AtomicLimit) OnChange(_, _ Setting) {}
+
+func (a *AtomicLimit) Watch(ctx context.Context) <-chan Setting {
+	ch := make(chan Setting, 1)
+	a.mu.Lock()
+	a.watchers[ch] = struct{}{}
+	a.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.watchers, ch)
+		close(ch)
+	})
+	return ch
+}
@@ -0,0 +1,141 @@
+package rate
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+func TestWatch(t *testing.T) {
+	suite.Run(t, new(TestSuiteWatch))
+}
+
+type TestSuiteWatch struct {
+	suite.Suite
+}
+
+// pushedLimit is a DynamicLimit pushing the limits given to push.
+type pushedLimit struct {
+	limit    atomic.Value
+	ch       chan rate.Limit
+	changes  chan rate.Limit
+	watching atomic.Int32
+}
+
+func newPushedLimit() *pushedLimit {
+	p := &pushedLimit{ch: make(chan rate.Limit), changes: make(chan rate.Limit, 8)}
+	p.limit.Store(rate.Limit(1))
+	return p
+}
+
+func (p *pushedLimit) push(limit rate.Limit) {
+	p.limit.Store(limit)
+	p.ch <- limit
+}
+
+func (p *pushedLimit) Limit() rate.Limit         { return p.limit.Load().(rate.Limit) }
+func (p *pushedLimit) OnChange(limit rate.Limit) { p.changes <- limit }
+func (p *pushedLimit) Watch(ctx context.Context) <-chan rate.Limit {
+	p.watching.Add(1)
+	context.AfterFunc(ctx, func() { p.watching.Add(-1) })
+	return p.ch
+}
+
+func (s *TestSuiteWatch) TestAtomicLimitPushesImmediately() {
+	src := NewAtomicLimit(Setting{Limit: 1, Burst: 1})
+	rl := NewDynamicBurstLimiter2("test", 0, src)
+	defer rl.Close()
+	s.Require().Equal(rate.Limit(1), rl.Limit())
+
+	src.Store(Setting{Limit: 50, Burst: 5})
+	s.Require().Eventually(func() bool { return rl.Limit() == 50 && rl.Burst() == 5 },
+		time.Second, time.Millisecond, "applied without polling")
+	s.Require().Equal(Setting{Limit: 50, Burst: 5}, src.Setting())
+}
+
+func (s *TestSuiteWatch) TestAtomicLimitKeepsLatest() {
+	src := NewAtomicLimit(Setting{Limit: 1, Burst: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := src.Watch(ctx)
+	for i := range 5 {
+		src.Store(Setting{Limit: rate.Limit(i + 2), Burst: 1})
+	}
+	s.Require().Equal(Setting{Limit: 6, Burst: 1}, <-ch, "a slow watcher only gets the latest setting")
+
+	cancel()
+	s.Require().Eventually(func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, time.Millisecond, "channel is closed once ctx is done")
+	src.Store(Setting{Limit: 7, Burst: 1})
+}
+
+func (s *TestSuiteWatch) TestLimitWatcher() {
+	src := newPushedLimit()
+	rl := NewDynamicLimiter2("test", 10*time.Millisecond, src)
+	defer rl.Close()
+
+	src.push(20)
+	s.Require().Equal(rate.Limit(20), <-src.changes)
+	s.Require().Equal(rate.Limit(20), rl.Limit())
+	s.Require().Equal(20, rl.Burst())
+
+	close(src.ch)
+	src.limit.Store(rate.Limit(3))
+	s.Require().Equal(rate.Limit(3), <-src.changes, "closed watch falls back to polling")
+}
+
+func (s *TestSuiteWatch) TestPollingFallback() {
+	src := NewAtomicLimit(Setting{Limit: 1, Burst: 1})
+	rl := NewDynamicBurstLimiter2("test", 10*time.Millisecond, pollOnly{src})
+	defer rl.Close()
+
+	src.Store(Setting{Limit: 3, Burst: 3})
+	s.Require().Eventually(func() bool { return rl.Limit() == 3 }, time.Second, time.Millisecond)
+}
+
+func (s *TestSuiteWatch) TestZeroIntervalNeedsWatcher() {
+	src := NewAtomicLimit(Setting{Limit: 1, Burst: 1})
+	s.Panics(func() { NewDynamicBurstLimiter2("test", 0, pollOnly{src}) })
+	s.Panics(func() { NewDynamicLimiter2("test", 0, staticLimit(1)) })
+	s.Panics(func() { NewDistributedLimiter("test", NewMemoryStore(), -time.Second, staticLimit(1)) })
+}
+
+func (s *TestSuiteWatch) TestDistributedLimiter() {
+	src := newPushedLimit()
+	l := NewDistributedLimiter("test", NewMemoryStore(), 0, src)
+	defer l.Close()
+
+	src.push(7)
+	s.Require().Equal(rate.Limit(7), <-src.changes)
+	s.Require().Equal(rate.Limit(7), l.Limit())
+	s.Require().Equal(7, l.Burst())
+}
+
+func (s *TestSuiteWatch) TestAtomicLimitDistributed() {
+	src := NewAtomicLimit(Setting{Limit: 1, Burst: 3})
+	l := NewDistributedBurstLimiter("test", NewMemoryStore(), 0, src)
+	defer l.Close()
+	s.Require().Equal(rate.Limit(1), l.Limit())
+	s.Require().Equal(3, l.Burst())
+
+	src.Store(Setting{Limit: 50, Burst: 5})
+	s.Require().Eventually(func() bool { return l.Limit() == 50 && l.Burst() == 5 },
+		time.Second, time.Millisecond, "applied without polling")
+	s.True(l.AllowN(time.Now(), 5))
+}
+
+func (s *TestSuiteWatch) TestCloseStopsWatching() {
+	src := newPushedLimit()
+	rl := NewDynamicLimiter2("test", 0, src)
+	s.Require().Eventually(func() bool { return src.watching.Load() == 1 }, time.Second, time.Millisecond)
+	rl.Close()
+	s.Eventually(func() bool { return src.watching.Load() == 0 }, time.Second, time.Millisecond)
+}
+
+// pollOnly hides the Watch method of a DynamicLimitBurst.
+type pollOnly struct{ DynamicLimitBurst }
@@ -0,0 +1,57 @@
+package routine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestClock(t *testing.T) {
+	suite.Run(t, new(TestSuiteClock))
+}
+
+type TestSuiteClock struct {
+	suite.Suite
+}
+
+func (s *TestSuiteClock) TestFakeTimerFiresOnAdvance() {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	t := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-t.C():
+		s.Fail("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	s.Equal(start.Add(time.Second), <-t.C())
+	s.False(t.Stop())
+}
+
+func (s *TestSuiteClock) TestFakeTimerStopAndReset() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	t := clock.NewTimer(time.Second)
+	s.True(t.Stop())
+
+	clock.Advance(time.Hour)
+	select {
+	case <-t.C():
+		s.Fail("stopped timer fired")
+	default:
+	}
+
+	s.False(t.Reset(time.Minute))
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-t.C()
+}
+
+func (s *TestSuiteClock) TestSystemClock() {
+	t := SystemClock.NewTimer(time.Millisecond)
+	<-t.C()
+	s.False(SystemClock.Now().IsZero())
+}
@@ -0,0 +1,162 @@
+package routine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// CrashOption configures Crash.
+type CrashOption func(*crashConfig)
+
+type crashConfig struct {
+	dir         string
+	writer      io.Writer
+	hooks       []func(context.Context)
+	hookTimeout time.Duration
+}
+
+// WithCrashDir writes the crash report to a new file in dir, named
+// crash-<timestamp>-<pid>.txt.
+func WithCrashDir(dir string) CrashOption {
+	return func(c *crashConfig) {
+		c.dir = dir
+	}
+}
+
+// WithCrashWriter writes the crash report to w. Defaults to stderr when no
+// crash directory is set either.
+func WithCrashWriter(w io.Writer) CrashOption {
+	return func(c *crashConfig) {
+		c.writer = w
+	}
+}
+
+// WithCrashHook adds a hook for this call, run after the hooks registered
+// with RegisterCrashHook.
+func WithCrashHook(fn func(context.Context)) CrashOption {
+	return func(c *crashConfig) {
+		c.hooks = append(c.hooks, fn)
+	}
+}
+
+// WithCrashHookTimeout bounds how long Crash waits for hooks. Defaults to
+// five seconds.
+func WithCrashHookTimeout(d time.Duration) CrashOption {
+	return func(c *crashConfig) {
+		c.hookTimeout = d
+	}
+}
+
+var (
+	crashHooksMu sync.Mutex
+	crashHooks   []func(context.Context)
+)
+
+// RegisterCrashHook adds a hook run by every Crash before the process dies,
+// e.g. to flush logs or metrics. Hooks run concurrently, with panics
+// recovered, and receive a context that expires at the hook deadline.
+func RegisterCrashHook(fn func(context.Context)) {
+	crashHooksMu.Lock()
+	defer crashHooksMu.Unlock()
+	crashHooks = append(crashHooks, fn)
+}
+
+// Crash is a controlled variant of Panic: it writes a crash report with the
+// reason, the caller stack, a dump of all goroutines and the build info,
+// runs the crash hooks until they finish or time out, and then triggers an
+// unrecoverable panic like Panic does.
+func Crash(v any, options ...CrashOption) {
+	// skip: callers + Crash
+	stack := callers(1, 0)
+	cfg := crashConfig{hookTimeout: 5 * time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	report := crashReport(v, stack, time.Now())
+	location := writeCrashReport(&cfg, report)
+	runCrashHooks(&cfg)
+
+	msg := fmt.Sprintf("%v\n\n%s", v, formatFrames(stack))
+	if location != "" {
+		msg += "\ncrash report: " + location
+	}
+	go func() {
+		panic(msg)
+	}()
+
+	select {}
+}
+
+func crashReport(v any, stack []uintptr, now time.Time) []byte {
+	buf := fmt.Appendf(nil, "crash report\n\ntime: %s\nreason: %v\npid: %d\ngo: %s\n",
+		now.Format(time.RFC3339Nano), v, os.Getpid(), runtime.Version())
+	if info, ok := debug.ReadBuildInfo(); ok {
+		buf = fmt.Appendf(buf, "\nbuild info:\n%s", info)
+	}
+	buf = fmt.Appendf(buf, "\ncaller stack:\n%s", formatFrames(stack))
+
+	return fmt.Appendf(buf, "\ngoroutines:\n%s\n", allStacks())
+}
+
+// writeCrashReport writes the report and returns where it went, for the
+// panic message. Write failures are reported on stderr and do not stop the
+// crash.
+func writeCrashReport(cfg *crashConfig, report []byte) string {
+	var location string
+	if cfg.dir != "" {
+		name := fmt.Sprintf("crash-%s-%d.txt", time.Now().Format("20060102T150405.000000000"), os.Getpid())
+		path := filepath.Join(cfg.dir, name)
+		if err := os.WriteFile(path, report, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "routine: write crash report: %v\n", err)
+		} else {
+			location = path
+		}
+	}
+	if cfg.writer != nil {
+		if _, err := cfg.writer.Write(report); err != nil {
+			fmt.Fprintf(os.Stderr, "routine: write crash report: %v\n", err)
+		}
+	} else if cfg.dir == "" {
+		os.Stderr.Write(report)
+	}
+	return location
+}
+
+func runCrashHooks(cfg *crashConfig) {
+	crashHooksMu.Lock()
+	hooks := append(append([]func(context.Context){}, crashHooks...), cfg.hooks...)
+	crashHooksMu.Unlock()
+	if len(hooks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.hookTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		Go(func() {
+			defer wg.Done()
+			hook(ctx)
+		}, WithCallerStack(false))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		fmt.Fprintf(os.Stderr, "routine: crash hooks did not finish within %s\n", cfg.hookTimeout)
+	}
+}
@@ -0,0 +1,59 @@
+package routine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCrash(t *testing.T) {
+	suite.Run(t, new(TestSuiteCrash))
+}
+
+type TestSuiteCrash struct {
+	suite.Suite
+}
+
+func (s *TestSuiteCrash) TestCrashWritesReport() {
+	if dir := os.Getenv("TEST_CRASH_DIR"); dir != "" {
+		RegisterCrashHook(func(context.Context) {
+			os.WriteFile(filepath.Join(dir, "hook-ran"), nil, 0o644)
+		})
+		Crash("controlled crash test",
+			WithCrashDir(dir),
+			WithCrashHook(func(context.Context) { panic("hook panic must not stop the crash") }),
+			WithCrashHook(func(ctx context.Context) { <-ctx.Done() }),
+			WithCrashHookTimeout(100*time.Millisecond),
+		)
+		return
+	}
+
+	dir := s.T().TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=TestCrash/TestCrashWritesReport")
+	cmd.Env = append(os.Environ(), "TEST_CRASH_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+
+	s.Error(err, "process should have exited with non-zero status")
+	output := string(out)
+	s.T().Logf("crash output:\n%s", output)
+	s.Contains(output, "controlled crash test")
+	s.Contains(output, "crash report: "+dir)
+	s.Contains(output, "crash hooks did not finish")
+
+	s.FileExists(filepath.Join(dir, "hook-ran"))
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	s.Require().NoError(err)
+	s.Require().Len(reports, 1)
+	report, err := os.ReadFile(reports[0])
+	s.Require().NoError(err)
+	s.Contains(string(report), "reason: controlled crash test")
+	s.Contains(string(report), "build info:")
+	s.Contains(string(report), "caller stack:")
+	s.Contains(string(report), "crash_test.go")
+	s.Contains(string(report), "goroutines:\ngoroutine ")
+}
@@ -0,0 +1,81 @@
+package routine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCron(t *testing.T) {
+	suite.Run(t, new(TestSuiteCron))
+}
+
+type TestSuiteCron struct {
+	suite.Suite
+}
+
+// 2024-01-01 is a Monday
+var cronBase = time.Date(2024, 1, 1, 10, 30, 15, 0, time.UTC)
+
+func (s *TestSuiteCron) next(spec string, from time.Time) time.Time {
+	sched, err := ParseCron(spec)
+	s.Require().NoError(err, spec)
+	return sched.Next(from)
+}
+
+func (s *TestSuiteCron) TestFields() {
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"5,10 9-17 * * *", time.Date(2024, 1, 1, 11, 5, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * sat", time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		// day-of-month OR day-of-week when both are restricted
+		{"0 0 15 * fri", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+	} {
+		s.Equal(tc.want, s.next(tc.spec, cronBase), tc.spec)
+	}
+}
+
+func (s *TestSuiteCron) TestDescriptors() {
+	s.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), s.next("@yearly", cronBase))
+	s.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), s.next("@monthly", cronBase))
+	s.Equal(time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), s.next("@weekly", cronBase))
+	s.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), s.next("@daily", cronBase))
+	s.Equal(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), s.next("@hourly", cronBase))
+	s.Equal(cronBase.Add(90*time.Second), s.next("@every 90s", cronBase))
+}
+
+func (s *TestSuiteCron) TestUnsatisfiable() {
+	s.True(s.next("0 0 30 2 *", cronBase).IsZero())
+}
+
+func (s *TestSuiteCron) TestInvalid() {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every -1s",
+		"@every soon",
+	} {
+		_, err := ParseCron(spec)
+		s.Error(err, spec)
+	}
+}
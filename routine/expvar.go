@@ -0,0 +1,63 @@
+package routine
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// ExpvarObserver is an Observer that publishes counters through expvar,
+// so they are served on /debug/vars. Each observed name becomes a map with
+// the keys started, finished, failed, panicked, running and duration_ns.
+type ExpvarObserver struct {
+	mu   sync.Mutex
+	vars *expvar.Map
+}
+
+// NewExpvarObserver publishes the counters under the expvar name. If name is
+// already published as an *expvar.Map, it is reused; any other variable with
+// that name causes a panic, as in expvar.Publish.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return &ExpvarObserver{vars: v}
+	}
+	return &ExpvarObserver{vars: expvar.NewMap(name)}
+}
+
+// OnStart increments started and running.
+func (o *ExpvarObserver) OnStart(name string) {
+	m := o.entry(name)
+	m.Add("started", 1)
+	m.Add("running", 1)
+}
+
+// OnFinish increments finished and, on error, failed; it decrements
+// running and adds d to duration_ns.
+func (o *ExpvarObserver) OnFinish(name string, d time.Duration, err error) {
+	m := o.entry(name)
+	m.Add("finished", 1)
+	m.Add("running", -1)
+	m.Add("duration_ns", int64(d))
+	if err != nil {
+		m.Add("failed", 1)
+	}
+}
+
+// OnPanic increments panicked.
+func (o *ExpvarObserver) OnPanic(name string, _ *PanicError) {
+	o.entry(name).Add("panicked", 1)
+}
+
+func (o *ExpvarObserver) entry(name string) *expvar.Map {
+	if m, ok := o.vars.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if m, ok := o.vars.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	o.vars.Set(name, m)
+	return m
+}
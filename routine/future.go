@@ -0,0 +1,201 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoFutures is the error of Any and Race called without futures.
+var ErrNoFutures = errors.New("routine: no futures to wait for")
+
+// Future is the eventual result of an asynchronous task started by Async or
+// derived by a combinator. It completes exactly once.
+type Future[T any] struct {
+	once sync.Once
+	done chan struct{}
+	val  T
+	err  error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+func (f *Future[T]) complete(val T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+	})
+}
+
+// Async runs fn in a new goroutine and returns a Future of its result.
+// A panic in fn is recovered into the Future's error as a *PanicError, with
+// the caller stack pointing at the call of Async.
+func Async[T any](ctx context.Context, fn func(context.Context) (T, error), options ...Option) *Future[T] {
+	// WithCallerSkip(1) skips Async itself, as in Go.
+	opts := append([]Option{WithCallerSkip(1)}, options...)
+	f := newFuture[T]()
+	var val T
+	run := Recover(func() (err error) {
+		val, err = fn(ctx)
+		return err
+	}, opts...)
+	go func() {
+		err := run()
+		f.complete(val, err)
+	}()
+	return f
+}
+
+// Done returns a channel that is closed when the Future completes.
+func (f *Future[T]) Done() <-chan struct{} { return f.done }
+
+// Get waits for the Future to complete and returns its result, or ctx.Err()
+// if ctx is done first.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// All returns a Future of every value, in the order of futures. It fails
+// with the first error as soon as any future fails, or with ctx.Err().
+func All[T any](ctx context.Context, futures ...*Future[T]) *Future[[]T] {
+	out := newFuture[[]T]()
+	if len(futures) == 0 {
+		out.complete([]T{}, nil)
+		return out
+	}
+
+	vals := make([]T, len(futures))
+	var remaining atomic.Int64
+	remaining.Store(int64(len(futures)))
+	for i, f := range futures {
+		Go(func() {
+			select {
+			case <-f.done:
+				if f.err != nil {
+					out.complete(nil, f.err)
+					return
+				}
+				vals[i] = f.val
+				// the last future to succeed completes out
+				if remaining.Add(-1) == 0 {
+					out.complete(vals, nil)
+				}
+			case <-ctx.Done():
+				out.complete(nil, ctx.Err())
+			case <-out.done:
+			}
+		})
+	}
+	return out
+}
+
+// Any returns a Future of the first successful value. If every future
+// fails, it fails with all their errors joined.
+func Any[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	out := newFuture[T]()
+	if len(futures) == 0 {
+		out.complete(*new(T), ErrNoFutures)
+		return out
+	}
+
+	errs := make([]error, len(futures))
+	var remaining atomic.Int64
+	remaining.Store(int64(len(futures)))
+	for i, f := range futures {
+		Go(func() {
+			select {
+			case <-f.done:
+				if f.err == nil {
+					out.complete(f.val, nil)
+					return
+				}
+				errs[i] = f.err
+				// the last future to fail completes out
+				if remaining.Add(-1) == 0 {
+					out.complete(*new(T), errors.Join(errs...))
+				}
+			case <-ctx.Done():
+				out.complete(*new(T), ctx.Err())
+			case <-out.done:
+			}
+		})
+	}
+	return out
+}
+
+// Race returns a Future completing with the result of whichever future
+// completes first, successfully or not.
+func Race[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	out := newFuture[T]()
+	if len(futures) == 0 {
+		out.complete(*new(T), ErrNoFutures)
+		return out
+	}
+
+	for _, f := range futures {
+		Go(func() {
+			select {
+			case <-f.done:
+				out.complete(f.val, f.err)
+			case <-ctx.Done():
+				out.complete(*new(T), ctx.Err())
+			case <-out.done:
+			}
+		})
+	}
+	return out
+}
+
+// Then returns a Future of fn applied to the value of f. If f fails, fn is
+// not called and the returned Future fails with the same error. A panic in
+// fn is recovered as in Async.
+func Then[T, R any](ctx context.Context, f *Future[T], fn func(context.Context, T) (R, error), options ...Option) *Future[R] {
+	// WithCallerSkip(1) skips Then itself.
+	opts := append([]Option{WithCallerSkip(1)}, options...)
+	out := newFuture[R]()
+	var val R
+	run := Recover(func() (err error) {
+		var in T
+		if in, err = f.Get(ctx); err != nil {
+			return err
+		}
+		val, err = fn(ctx, in)
+		return err
+	}, opts...)
+	go func() {
+		err := run()
+		out.complete(val, err)
+	}()
+	return out
+}
+
+// Map returns a Future of fn applied to the value of f, like Then for
+// functions that cannot fail.
+func Map[T, R any](f *Future[T], fn func(T) R, options ...Option) *Future[R] {
+	// WithCallerSkip(1) skips Map itself.
+	opts := append([]Option{WithCallerSkip(1)}, options...)
+	out := newFuture[R]()
+	var val R
+	run := Recover(func() error {
+		<-f.done
+		if f.err != nil {
+			return f.err
+		}
+		val = fn(f.val)
+		return nil
+	}, opts...)
+	go func() {
+		err := run()
+		out.complete(val, err)
+	}()
+	return out
+}
@@ -0,0 +1,39 @@
+package routine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yeluyang/gopkg/routine"
+	"github.com/yeluyang/gopkg/routine/routinetest"
+)
+
+func TestCombinatorsDoNotLeak(t *testing.T) {
+	defer routinetest.VerifyNone(t, routinetest.IgnoreCurrent())
+
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+	ok := func(v int) *routine.Future[int] {
+		return routine.Async(ctx, func(context.Context) (int, error) { return v, nil })
+	}
+	fail := func() *routine.Future[int] {
+		return routine.Async(ctx, func(context.Context) (int, error) { return 0, errFailed })
+	}
+
+	if _, err := routine.All(ctx, ok(1), ok(2)).Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := routine.All(ctx, ok(1), fail()).Get(ctx); !errors.Is(err, errFailed) {
+		t.Fatalf("All: got %v, want %v", err, errFailed)
+	}
+	if _, err := routine.Any(ctx, fail(), ok(2)).Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := routine.Any(ctx, fail(), fail()).Get(ctx); !errors.Is(err, errFailed) {
+		t.Fatalf("Any: got %v, want %v", err, errFailed)
+	}
+	if _, err := routine.Race(ctx, ok(1), fail()).Get(ctx); err != nil && !errors.Is(err, errFailed) {
+		t.Fatal(err)
+	}
+}
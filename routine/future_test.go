@@ -0,0 +1,142 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFuture(t *testing.T) {
+	suite.Run(t, new(TestSuiteFuture))
+}
+
+type TestSuiteFuture struct {
+	suite.Suite
+}
+
+var errFuture = errors.New("future failed")
+
+func value[T any](v T) func(context.Context) (T, error) {
+	return func(context.Context) (T, error) { return v, nil }
+}
+
+func failure[T any](err error) func(context.Context) (T, error) {
+	return func(context.Context) (T, error) { return *new(T), err }
+}
+
+// blocked returns a task that waits for release before returning v.
+func blocked[T any](v T, release <-chan struct{}) func(context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		<-release
+		return v, nil
+	}
+}
+
+func (s *TestSuiteFuture) TestGet() {
+	ctx := context.Background()
+	v, err := Async(ctx, value(42)).Get(ctx)
+	s.NoError(err)
+	s.Equal(42, v)
+
+	_, err = Async(ctx, failure[int](errFuture)).Get(ctx)
+	s.ErrorIs(err, errFuture)
+}
+
+func (s *TestSuiteFuture) TestGetHonorsContext() {
+	release := make(chan struct{})
+	defer close(release)
+	f := Async(context.Background(), blocked(1, release))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := f.Get(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	select {
+	case <-f.Done():
+		s.Fail("future should not be done")
+	default:
+	}
+}
+
+func (s *TestSuiteFuture) TestPanicRecovered() {
+	ctx := context.Background()
+	_, err := Async(ctx, func(context.Context) (int, error) { panic("future panic") }).Get(ctx)
+
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Equal("future panic", pe.Value)
+	s.Contains(err.Error(), "future_test.go")
+}
+
+func (s *TestSuiteFuture) TestAll() {
+	ctx := context.Background()
+	vals, err := All(ctx, Async(ctx, value(1)), Async(ctx, value(2)), Async(ctx, value(3))).Get(ctx)
+	s.NoError(err)
+	s.Equal([]int{1, 2, 3}, vals)
+
+	vals, err = All[int](ctx).Get(ctx)
+	s.NoError(err)
+	s.Empty(vals)
+}
+
+func (s *TestSuiteFuture) TestAllFailsFast() {
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	_, err := All(ctx, Async(ctx, blocked(1, release)), Async(ctx, failure[int](errFuture))).Get(ctx)
+	s.ErrorIs(err, errFuture)
+}
+
+func (s *TestSuiteFuture) TestAny() {
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	v, err := Any(ctx, Async(ctx, failure[int](errFuture)), Async(ctx, blocked(1, release)), Async(ctx, value(2))).Get(ctx)
+	s.NoError(err)
+	s.Equal(2, v)
+
+	errOther := errors.New("other")
+	_, err = Any(ctx, Async(ctx, failure[int](errFuture)), Async(ctx, failure[int](errOther))).Get(ctx)
+	s.ErrorIs(err, errFuture)
+	s.ErrorIs(err, errOther)
+
+	_, err = Any[int](ctx).Get(ctx)
+	s.ErrorIs(err, ErrNoFutures)
+}
+
+func (s *TestSuiteFuture) TestRace() {
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	_, err := Race(ctx, Async(ctx, blocked(1, release)), Async(ctx, failure[int](errFuture))).Get(ctx)
+	s.ErrorIs(err, errFuture)
+
+	_, err = Race[int](ctx).Get(ctx)
+	s.ErrorIs(err, ErrNoFutures)
+}
+
+func (s *TestSuiteFuture) TestThenAndMap() {
+	ctx := context.Background()
+	f := Async(ctx, value(21))
+	doubled := Map(f, func(v int) int { return v * 2 })
+	str := Then(ctx, doubled, func(_ context.Context, v int) (string, error) { return strconv.Itoa(v), nil })
+
+	v, err := str.Get(ctx)
+	s.NoError(err)
+	s.Equal("42", v)
+
+	_, err = Map(Async(ctx, failure[int](errFuture)), func(v int) int { return v }).Get(ctx)
+	s.ErrorIs(err, errFuture)
+
+	_, err = Then(ctx, f, func(context.Context, int) (int, error) { panic("then panic") }).Get(ctx)
+	var pe *PanicError
+	s.ErrorAs(err, &pe)
+}
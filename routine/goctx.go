@@ -0,0 +1,90 @@
+package routine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LeakError is reported by GoCtx when its goroutine ignores cancellation and
+// is still running after the configured grace period.
+type LeakError struct {
+	Name  string
+	Grace time.Duration
+	// CallerStack holds the program counters of the call site of GoCtx.
+	CallerStack []uintptr
+
+	stack *stackConfig
+}
+
+func (e *LeakError) Error() string {
+	msg := fmt.Appendf(nil, "goroutine %q still running %s after its context was done", e.Name, e.Grace)
+	if len(e.CallerStack) > 0 {
+		msg = fmt.Appendf(msg, "\ncalled from:\n\n%s", e.stack.format(e.CallerStack))
+	}
+	return string(msg)
+}
+
+// GoCtx launches fn in a new goroutine with panic recovery, passing it ctx
+// bounded by WithTimeout and WithDeadline. The context is canceled when fn
+// returns. Unlike Go, errors returned by fn are passed to the error handler
+// as well as recovered panics.
+// With WithLeakGrace, a *LeakError is reported if fn is still running that
+// long after the context is done. WithWatchdog works as with Go.
+func GoCtx(ctx context.Context, fn func(context.Context) error, options ...Option) {
+	// WithCallerSkip(1) is the baseline to skip GoCtx itself, as in Go.
+	opts := append([]Option{WithCallerSkip(1)}, options...)
+	cfg := newConfig(opts)
+	errHandler := cfg.handler()
+
+	deadline := cfg.deadline
+	if cfg.timeout > 0 {
+		if d := time.Now().Add(cfg.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	var cancel context.CancelFunc
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	}
+	launch := launchStack(&cfg)
+	wrapped := recoverWith(&cfg, callerStack(&cfg, launch), func() error { return fn(ctx) })
+
+	var record *GoroutineRecord
+	if tracking.Load() {
+		record = newRecord(cfg.name, launch)
+		record.register()
+	}
+
+	go func() {
+		defer cancel()
+		if record != nil {
+			record.start()
+			defer record.unregister()
+		}
+
+		var finished atomic.Bool
+		stopLeakCheck := func() bool { return false }
+		if cfg.leakGrace > 0 {
+			stopLeakCheck = context.AfterFunc(ctx, func() {
+				time.AfterFunc(cfg.leakGrace, func() {
+					if !finished.Load() {
+						errHandler(&LeakError{Name: cfg.name, Grace: cfg.leakGrace, CallerStack: launch, stack: &cfg.stack})
+					}
+				})
+			})
+		}
+
+		stopWatchdog := startWatchdog(&cfg, launch, errHandler)
+		err := wrapped()
+		stopWatchdog()
+		finished.Store(true)
+		stopLeakCheck()
+		if err != nil {
+			errHandler(err)
+		}
+	}()
+}
@@ -0,0 +1,129 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestGoCtx(t *testing.T) {
+	suite.Run(t, new(TestSuiteGoCtx))
+}
+
+type TestSuiteGoCtx struct {
+	suite.Suite
+}
+
+func (s *TestSuiteGoCtx) TestReportsReturnedError() {
+	errCh := make(chan error, 1)
+	errFailed := errors.New("failed")
+
+	GoCtx(context.Background(), func(context.Context) error { return errFailed },
+		WithErrorHandler(func(err error) { errCh <- err }))
+
+	s.ErrorIs(s.receive(errCh), errFailed)
+}
+
+func (s *TestSuiteGoCtx) TestRecoversPanic() {
+	errCh := make(chan error, 1)
+
+	GoCtx(context.Background(), func(context.Context) error { panic("ctx panic") },
+		WithErrorHandler(func(err error) { errCh <- err }))
+
+	err := s.receive(errCh)
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Contains(err.Error(), "goctx_test.go")
+}
+
+func (s *TestSuiteGoCtx) TestPropagatesCancellation() {
+	errCh := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	GoCtx(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithErrorHandler(func(err error) { errCh <- err }))
+
+	cancel()
+	s.ErrorIs(s.receive(errCh), context.Canceled)
+}
+
+func (s *TestSuiteGoCtx) TestTimeout() {
+	errCh := make(chan error, 1)
+
+	GoCtx(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(10*time.Millisecond), WithErrorHandler(func(err error) { errCh <- err }))
+
+	s.ErrorIs(s.receive(errCh), context.DeadlineExceeded)
+}
+
+func (s *TestSuiteGoCtx) TestDeadline() {
+	deadlines := make(chan time.Time, 1)
+	want := time.Now().Add(time.Hour)
+
+	GoCtx(context.Background(), func(ctx context.Context) error {
+		d, _ := ctx.Deadline()
+		deadlines <- d
+		return nil
+	}, WithDeadline(want), WithTimeout(2*time.Hour))
+
+	select {
+	case got := <-deadlines:
+		s.True(want.Equal(got), "earlier deadline should win: %s", got)
+	case <-time.After(time.Second):
+		s.Fail("goroutine did not run")
+	}
+}
+
+func (s *TestSuiteGoCtx) TestLeakWarning() {
+	errCh := make(chan error, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	GoCtx(ctx, func(context.Context) error {
+		<-release // ignores ctx
+		return nil
+	}, WithName("stubborn"), WithLeakGrace(20*time.Millisecond),
+		WithErrorHandler(func(err error) { errCh <- err }))
+
+	cancel()
+	err := s.receive(errCh)
+	var le *LeakError
+	s.Require().ErrorAs(err, &le)
+	s.Equal("stubborn", le.Name)
+	s.Contains(err.Error(), "goctx_test.go")
+}
+
+func (s *TestSuiteGoCtx) TestNoLeakWarningWhenExitingInTime() {
+	errCh := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	GoCtx(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}, WithLeakGrace(20*time.Millisecond), WithErrorHandler(func(err error) { errCh <- err }))
+
+	cancel()
+	select {
+	case err := <-errCh:
+		s.Failf("unexpected error", "%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *TestSuiteGoCtx) receive(errCh <-chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(time.Second):
+		s.FailNow("no error reported within timeout")
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package routine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group is a collection of goroutines working on subtasks of the same
+// overall task, like golang.org/x/sync/errgroup.Group. Every member is
+// wrapped with Recover, so a panic becomes the group's error instead of
+// crashing the process.
+//
+// A zero Group is valid, has no limit on the number of active goroutines,
+// and does not cancel on error.
+type Group struct {
+	cancel  func(error)
+	options []Option
+
+	wg  sync.WaitGroup
+	sem chan struct{}
+
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext returns a new Group and an associated Context derived from ctx.
+// The derived Context is canceled the first time a member returns a non-nil
+// error or panics, or the first time Wait returns, whichever occurs first.
+// The options apply to every member and can be overridden per call.
+func WithContext(ctx context.Context, options ...Option) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel, options: options}, ctx
+}
+
+// Go calls fn in a new goroutine with panic recovery.
+// It blocks until the new goroutine can be added without the number of
+// active goroutines exceeding the configured limit.
+// The first non-nil error or panic cancels the group's context and is
+// returned by Wait. WithErrorHandler has no effect on group members.
+func (g *Group) Go(fn func() error, options ...Option) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.run(Recover(fn, g.opts(options)...))
+}
+
+// TryGo calls fn in a new goroutine only if the number of active goroutines
+// is currently below the configured limit. It reports whether fn was started.
+func (g *Group) TryGo(fn func() error, options ...Option) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.run(Recover(fn, g.opts(options)...))
+	return true
+}
+
+// Wait blocks until all members have returned, then returns the first
+// non-nil error (or recovered panic) from them.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
+
+// SetLimit limits the number of active goroutines in this group to at most n.
+// A negative value indicates no limit. A limit of zero prevents any new
+// goroutines from being added.
+// The limit must not be modified while any goroutines in the group are active.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("routine: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// opts builds the Recover options for a member: WithCallerSkip(1) skips
+// Go/TryGo, then group options, then per-call options.
+func (g *Group) opts(options []Option) []Option {
+	opts := make([]Option, 0, 1+len(g.options)+len(options))
+	opts = append(opts, WithCallerSkip(1))
+	opts = append(opts, g.options...)
+	return append(opts, options...)
+}
+
+func (g *Group) run(fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := fn(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
@@ -0,0 +1,106 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestGroup(t *testing.T) {
+	suite.Run(t, new(TestSuiteGroup))
+}
+
+type TestSuiteGroup struct {
+	suite.Suite
+}
+
+func (s *TestSuiteGroup) TestZeroGroup() {
+	var g Group
+	var counter atomic.Int32
+	for range 10 {
+		g.Go(func() error {
+			counter.Add(1)
+			return nil
+		})
+	}
+	s.NoError(g.Wait())
+	s.Equal(int32(10), counter.Load())
+}
+
+func (s *TestSuiteGroup) TestWaitReturnsFirstError() {
+	errFirst := errors.New("first")
+	g, ctx := WithContext(context.Background())
+
+	g.Go(func() error { return errFirst })
+	g.Go(func() error {
+		<-ctx.Done()
+		return errors.New("second")
+	})
+
+	s.ErrorIs(g.Wait(), errFirst)
+	s.ErrorIs(context.Cause(ctx), errFirst)
+}
+
+func (s *TestSuiteGroup) TestPanicCancelsContext() {
+	g, ctx := WithContext(context.Background())
+
+	s.groupCaller(g)
+	g.Go(func() error {
+		<-ctx.Done()
+		return nil
+	})
+
+	err := g.Wait()
+	s.Require().Error(err)
+	s.T().Logf("group error:\n%s", err.Error())
+	s.Contains(err.Error(), "panic: group panic")
+	s.Contains(err.Error(), "groupCaller")
+	s.Equal(err, context.Cause(ctx))
+}
+
+func (s *TestSuiteGroup) TestPerCallOptions() {
+	g, _ := WithContext(context.Background(), WithPanicStack(false))
+
+	g.Go(func() error { panic("no stacks") }, WithCallerStack(false))
+
+	err := g.Wait()
+	s.Require().Error(err)
+	s.Equal("panic: no stacks", err.Error())
+}
+
+func (s *TestSuiteGroup) TestSetLimitAndTryGo() {
+	var g Group
+	g.SetLimit(1)
+
+	release := make(chan struct{})
+	s.True(g.TryGo(func() error {
+		<-release
+		return nil
+	}))
+	s.False(g.TryGo(func() error { return nil }))
+
+	close(release)
+	s.NoError(g.Wait())
+	s.True(g.TryGo(func() error { return nil }))
+	s.NoError(g.Wait())
+}
+
+func (s *TestSuiteGroup) TestWaitCancelsContext() {
+	g, ctx := WithContext(context.Background())
+	g.Go(func() error { return nil })
+	s.NoError(g.Wait())
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		s.Fail("context was not canceled after Wait")
+	}
+}
+
+func (s *TestSuiteGroup) groupCaller(g *Group) {
+	g.Go(func() error { panic("group panic") })
+}
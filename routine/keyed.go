@@ -0,0 +1,185 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLaneFull is returned by KeyedExecutor.Submit when the queue of the
+	// key already holds the maximum number of tasks.
+	ErrLaneFull = errors.New("routine: keyed executor lane is full")
+	// ErrExecutorClosed is returned by KeyedExecutor.Submit after Shutdown
+	// has been called.
+	ErrExecutorClosed = errors.New("routine: keyed executor is shut down")
+)
+
+// KeyedOption configures a KeyedExecutor.
+type KeyedOption func(*keyedConfig)
+
+type keyedConfig struct {
+	depth       int
+	idleTimeout time.Duration
+	options     []Option
+}
+
+// WithLaneDepth bounds the number of tasks queued per key, not counting the
+// running one. Defaults to 1024; zero or less means unbounded.
+func WithLaneDepth(n int) KeyedOption {
+	return func(c *keyedConfig) {
+		c.depth = n
+	}
+}
+
+// WithLaneIdleTimeout sets how long the goroutine of a key waits for a new
+// task before exiting. Defaults to one second.
+func WithLaneIdleTimeout(d time.Duration) KeyedOption {
+	return func(c *keyedConfig) {
+		c.idleTimeout = d
+	}
+}
+
+// WithKeyedTaskOptions sets default Recover options for every submitted task,
+// such as WithErrorHandler. Options passed to Submit follow and override them.
+func WithKeyedTaskOptions(options ...Option) KeyedOption {
+	return func(c *keyedConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// KeyedExecutor runs tasks with the same key one at a time in submission
+// order, while tasks with different keys run in parallel. Each key with
+// pending work gets a lane: a queue served by its own goroutine, which exits
+// after the idle timeout. Every task is wrapped with Recover; panics are
+// reported to the task's error handler and the lane carries on.
+type KeyedExecutor[K comparable] struct {
+	cfg keyedConfig
+
+	mu      sync.Mutex
+	lanes   map[K]*lane
+	closed  bool
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+type lane struct {
+	queue []poolTask
+	wake  chan struct{}
+}
+
+// NewKeyedExecutor returns an executor with no lanes yet.
+func NewKeyedExecutor[K comparable](options ...KeyedOption) *KeyedExecutor[K] {
+	cfg := keyedConfig{depth: 1024, idleTimeout: time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	return &KeyedExecutor[K]{cfg: cfg, lanes: make(map[K]*lane), closing: make(chan struct{})}
+}
+
+// Submit queues fn on the lane of key.
+// The caller stack is captured here, so panics point back to the submit site.
+// It returns ErrExecutorClosed after Shutdown, and ErrLaneFull when the lane
+// is at its depth.
+func (e *KeyedExecutor[K]) Submit(key K, fn func(), options ...Option) error {
+	// WithCallerSkip(1) skips Submit itself; executor and per-call options follow.
+	opts := make([]Option, 0, 1+len(e.cfg.options)+len(options))
+	opts = append(opts, WithCallerSkip(1))
+	opts = append(opts, e.cfg.options...)
+	opts = append(opts, options...)
+	cfg := newConfig(opts)
+	t := poolTask{
+		run:     Recover(func() error { fn(); return nil }, opts...),
+		handler: cfg.handler(),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrExecutorClosed
+	}
+	l, ok := e.lanes[key]
+	if !ok {
+		l = &lane{wake: make(chan struct{}, 1)}
+		e.lanes[key] = l
+		e.wg.Add(1)
+		go e.runLane(key, l)
+	}
+	if e.cfg.depth > 0 && len(l.queue) >= e.cfg.depth {
+		return ErrLaneFull
+	}
+	l.queue = append(l.queue, t)
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Lanes returns the number of keys with a live lane.
+func (e *KeyedExecutor[K]) Lanes() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.lanes)
+}
+
+// Shutdown stops accepting new tasks and waits until every queued task has
+// run, or ctx is done. It is safe to call Shutdown more than once.
+func (e *KeyedExecutor[K]) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.closing)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *KeyedExecutor[K]) runLane(key K, l *lane) {
+	defer e.wg.Done()
+	idle := time.NewTimer(e.cfg.idleTimeout)
+	defer idle.Stop()
+	for {
+		e.mu.Lock()
+		if len(l.queue) > 0 {
+			t := l.queue[0]
+			l.queue[0] = poolTask{}
+			l.queue = l.queue[1:]
+			e.mu.Unlock()
+			t.exec()
+			idle.Reset(e.cfg.idleTimeout)
+			continue
+		}
+		if e.closed {
+			delete(e.lanes, key)
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+
+		select {
+		case <-l.wake:
+		case <-e.closing:
+		case <-idle.C:
+			e.mu.Lock()
+			if len(l.queue) == 0 {
+				delete(e.lanes, key)
+				e.mu.Unlock()
+				return
+			}
+			e.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package routine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestKeyedExecutor(t *testing.T) {
+	suite.Run(t, new(TestSuiteKeyedExecutor))
+}
+
+type TestSuiteKeyedExecutor struct {
+	suite.Suite
+}
+
+func (s *TestSuiteKeyedExecutor) TestPerKeyOrder() {
+	e := NewKeyedExecutor[int]()
+	var mu sync.Mutex
+	got := make(map[int][]int)
+	for i := range 100 {
+		key := i % 4
+		s.Require().NoError(e.Submit(key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			got[key] = append(got[key], i)
+		}))
+	}
+	s.Require().NoError(e.Shutdown(context.Background()))
+
+	for key, seq := range got {
+		s.Len(seq, 25)
+		for j := 1; j < len(seq); j++ {
+			s.Less(seq[j-1], seq[j], "key %d out of order", key)
+		}
+	}
+	s.Zero(e.Lanes())
+}
+
+func (s *TestSuiteKeyedExecutor) TestKeysRunInParallel() {
+	e := NewKeyedExecutor[string]()
+	defer e.Shutdown(context.Background())
+
+	release := make(chan struct{})
+	s.Require().NoError(e.Submit("a", func() { <-release }))
+	done := make(chan struct{})
+	s.Require().NoError(e.Submit("b", func() { close(done) }))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("key b was blocked by key a")
+	}
+	close(release)
+}
+
+func (s *TestSuiteKeyedExecutor) TestPanicKeepsLane() {
+	errCh := make(chan error, 1)
+	e := NewKeyedExecutor[string](WithKeyedTaskOptions(WithErrorHandler(func(err error) { errCh <- err })))
+	defer e.Shutdown(context.Background())
+
+	s.Require().NoError(e.Submit("k", func() { panic("lane panic") }))
+	ran := make(chan struct{})
+	s.Require().NoError(e.Submit("k", func() { close(ran) }))
+
+	err := <-errCh
+	s.Contains(err.Error(), "panic: lane panic")
+	s.Contains(err.Error(), "keyed_test.go")
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		s.Fail("lane did not survive the panic")
+	}
+}
+
+func (s *TestSuiteKeyedExecutor) TestLaneDepth() {
+	e := NewKeyedExecutor[string](WithLaneDepth(1))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.Require().NoError(e.Submit("k", func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	s.Require().NoError(e.Submit("k", func() {}))
+	s.ErrorIs(e.Submit("k", func() {}), ErrLaneFull)
+	s.NoError(e.Submit("other", func() {}))
+
+	close(release)
+	s.NoError(e.Shutdown(context.Background()))
+	s.ErrorIs(e.Submit("k", func() {}), ErrExecutorClosed)
+}
+
+func (s *TestSuiteKeyedExecutor) TestIdleReclamation() {
+	e := NewKeyedExecutor[int](WithLaneIdleTimeout(20 * time.Millisecond))
+	defer e.Shutdown(context.Background())
+
+	var counter atomic.Int32
+	for key := range 3 {
+		s.Require().NoError(e.Submit(key, func() { counter.Add(1) }))
+	}
+	s.Eventually(func() bool { return e.Lanes() == 0 }, time.Second, 5*time.Millisecond)
+	s.Equal(int32(3), counter.Load())
+
+	done := make(chan struct{})
+	s.Require().NoError(e.Submit(0, func() { close(done) }))
+	<-done
+}
+
+func (s *TestSuiteKeyedExecutor) TestShutdownTimeout() {
+	e := NewKeyedExecutor[string]()
+	release := make(chan struct{})
+	s.Require().NoError(e.Submit("k", func() { <-release }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(e.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(release)
+	s.NoError(e.Shutdown(context.Background()))
+}
@@ -0,0 +1,288 @@
+package routine
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLifecycleStopped is the cause of a Lifecycle's context once it shuts
+// down, and is passed to the error handler of goroutines launched after that.
+var ErrLifecycleStopped = errors.New("routine: lifecycle is shutting down")
+
+// ShutdownError is returned by Lifecycle.Shutdown when tracked goroutines
+// are still running at the shutdown deadline.
+type ShutdownError struct {
+	// Err is the error of the context that expired.
+	Err error
+	// Leaked lists the goroutines that did not exit, oldest first.
+	Leaked []GoroutineRecord
+}
+
+func (e *ShutdownError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "routine: %d goroutines did not exit: %v\n", len(e.Leaked), e.Err)
+	writeDump(&buf, e.Leaked, time.Now())
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (e *ShutdownError) Unwrap() error { return e.Err }
+
+// LifecycleOption configures a Lifecycle.
+type LifecycleOption func(*lifecycleConfig)
+
+type lifecycleConfig struct {
+	signals []os.Signal
+	timeout time.Duration
+	options []Option
+}
+
+// WithSignals makes the lifecycle shut down when one of sigs is received.
+// Only the first signal is handled; a second one gets its default behavior,
+// so an impatient operator can still kill the process.
+func WithSignals(sigs ...os.Signal) LifecycleOption {
+	return func(c *lifecycleConfig) {
+		c.signals = append(c.signals, sigs...)
+	}
+}
+
+// WithShutdownTimeout bounds shutdowns triggered by a signal or by the
+// parent context. Defaults to 30 seconds.
+func WithShutdownTimeout(d time.Duration) LifecycleOption {
+	return func(c *lifecycleConfig) {
+		c.timeout = d
+	}
+}
+
+// WithLifecycleOptions sets default options for every goroutine launched by
+// Lifecycle.Go and every stop hook. Options passed to Go follow and override
+// them.
+func WithLifecycleOptions(options ...Option) LifecycleOption {
+	return func(c *lifecycleConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// Lifecycle coordinates the graceful shutdown of a service. It owns a root
+// context, tracks the goroutines launched through it and runs stop hooks.
+//
+// A shutdown, triggered by Shutdown, a configured signal or the parent
+// context, runs the stop hooks by priority, then cancels the root context and
+// waits for the tracked goroutines to return.
+type Lifecycle struct {
+	cfg    lifecycleConfig
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	hooks    []stopHook
+	running  map[uint64]*GoroutineRecord
+	wg       sync.WaitGroup
+	stopping bool
+
+	started bool
+	done    chan struct{}
+	err     error
+}
+
+type stopHook struct {
+	priority int
+	name     string
+	fn       func(context.Context) error
+}
+
+// NewLifecycle returns a Lifecycle whose root context derives from ctx.
+// Canceling ctx triggers a shutdown.
+func NewLifecycle(ctx context.Context, options ...LifecycleOption) *Lifecycle {
+	cfg := lifecycleConfig{timeout: 30 * time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	l := &Lifecycle{cfg: cfg, running: make(map[uint64]*GoroutineRecord), done: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancelCause(ctx)
+
+	var sigCh chan os.Signal
+	if len(cfg.signals) > 0 {
+		sigCh = make(chan os.Signal, 1)
+		signal.Notify(sigCh, cfg.signals...)
+	}
+	go func() {
+		select {
+		case <-sigCh:
+		case <-ctx.Done():
+		case <-l.done:
+		}
+		// stop catching signals before shutting down, so that a second
+		// signal gets its default behavior and can kill a stuck shutdown
+		if sigCh != nil {
+			signal.Stop(sigCh)
+		}
+		select {
+		case <-l.done:
+			return
+		default:
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.timeout)
+		defer cancel()
+		l.Shutdown(shutdownCtx)
+	}()
+	return l
+}
+
+// Context returns the root context, canceled with cause ErrLifecycleStopped
+// once the stop hooks have run.
+func (l *Lifecycle) Context() context.Context { return l.ctx }
+
+// Go launches fn in a tracked goroutine with panic recovery, passing it the
+// root context. Errors returned by fn are passed to the error handler as well
+// as recovered panics; WithWatchdog works as with Go. After a shutdown has
+// started, fn is not run and ErrLifecycleStopped is passed to the error
+// handler instead.
+func (l *Lifecycle) Go(fn func(context.Context) error, options ...Option) {
+	// WithCallerSkip(1) is the baseline to skip Go itself, as in Go;
+	// lifecycle and per-call options follow.
+	opts := make([]Option, 0, 1+len(l.cfg.options)+len(options))
+	opts = append(opts, WithCallerSkip(1))
+	opts = append(opts, l.cfg.options...)
+	opts = append(opts, options...)
+	cfg := newConfig(opts)
+	errHandler := cfg.handler()
+	// skip: the same frames above Go as Recover skips
+	launch := callers(cfg.callerSkip, cfg.stack.maxDepth)
+	wrapped := recoverWith(&cfg, callerStack(&cfg, launch), func() error { return fn(l.ctx) })
+	record := newRecord(cfg.name, launch)
+
+	l.mu.Lock()
+	if l.stopping {
+		l.mu.Unlock()
+		errHandler(ErrLifecycleStopped)
+		return
+	}
+	l.wg.Add(1)
+	l.running[record.ID] = record
+	l.mu.Unlock()
+
+	global := tracking.Load()
+	if global {
+		record.register()
+	}
+	go func() {
+		defer l.wg.Done()
+		l.mu.Lock()
+		record.GoroutineID = goid()
+		l.mu.Unlock()
+		if global {
+			record.register()
+			defer record.unregister()
+		}
+		defer func() {
+			l.mu.Lock()
+			delete(l.running, record.ID)
+			l.mu.Unlock()
+		}()
+
+		stopWatchdog := startWatchdog(&cfg, record.Stack, errHandler)
+		err := wrapped()
+		stopWatchdog()
+		if err != nil {
+			errHandler(err)
+		}
+	}()
+}
+
+// OnStop registers a hook run at shutdown, before the root context is
+// canceled. Hooks run one at a time, lower priorities first; hooks with the
+// same priority run in reverse order of registration, like defers. Hooks
+// registered after a shutdown has started are ignored.
+func (l *Lifecycle) OnStop(priority int, name string, fn func(context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.stopping {
+		l.hooks = append(l.hooks, stopHook{priority: priority, name: name, fn: fn})
+	}
+}
+
+// Shutdown runs the stop hooks, cancels the root context and waits for the
+// tracked goroutines to return, or for ctx to be done. In the latter case the
+// returned error includes a *ShutdownError listing the goroutines still
+// running. Errors and panics of the hooks are joined in the returned error.
+//
+// Only the first call performs the shutdown, bounded by its ctx; later calls
+// wait for it and return the same result.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	first := !l.started
+	l.started = true
+	l.mu.Unlock()
+	if first {
+		l.err = l.shutdown(ctx)
+		close(l.done)
+		return l.err
+	}
+	select {
+	case <-l.done:
+		return l.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until a shutdown has completed and returns its result.
+func (l *Lifecycle) Wait() error {
+	<-l.done
+	return l.err
+}
+
+// Done is closed once a shutdown has completed.
+func (l *Lifecycle) Done() <-chan struct{} { return l.done }
+
+func (l *Lifecycle) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.stopping = true
+	hooks := slices.Clone(l.hooks)
+	l.mu.Unlock()
+
+	slices.Reverse(hooks)
+	slices.SortStableFunc(hooks, func(a, b stopHook) int { return cmp.Compare(a.priority, b.priority) })
+	var errs []error
+	for _, h := range hooks {
+		opts := append(slices.Clip(l.cfg.options), WithName(h.name))
+		if err := Recover(func() error { return h.fn(ctx) }, opts...)(); err != nil {
+			errs = append(errs, fmt.Errorf("routine: stop hook %q: %w", h.name, err))
+		}
+	}
+
+	l.cancel(ErrLifecycleStopped)
+	exited := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		errs = append(errs, &ShutdownError{Err: ctx.Err(), Leaked: l.leaked()})
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Lifecycle) leaked() []GoroutineRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]GoroutineRecord, 0, len(l.running))
+	for _, r := range l.running {
+		records = append(records, *r)
+	}
+	slices.SortFunc(records, func(a, b GoroutineRecord) int {
+		return cmp.Or(a.Started.Compare(b.Started), cmp.Compare(a.ID, b.ID))
+	})
+	return records
+}
@@ -0,0 +1,131 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestLifecycle(t *testing.T) {
+	suite.Run(t, new(TestSuiteLifecycle))
+}
+
+type TestSuiteLifecycle struct {
+	suite.Suite
+}
+
+func (s *TestSuiteLifecycle) TestShutdownOrder() {
+	l := NewLifecycle(context.Background())
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	l.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		record("goroutine")
+		return nil
+	})
+	l.OnStop(10, "db", func(context.Context) error { record("db"); return nil })
+	l.OnStop(0, "http", func(context.Context) error { record("http"); return nil })
+	l.OnStop(0, "grpc", func(ctx context.Context) error {
+		s.NoError(l.Context().Err(), "root context canceled before hooks")
+		record("grpc")
+		return nil
+	})
+
+	s.NoError(l.Shutdown(context.Background()))
+	s.Equal([]string{"grpc", "http", "db", "goroutine"}, order)
+	s.ErrorIs(context.Cause(l.Context()), ErrLifecycleStopped)
+	s.NoError(l.Wait())
+}
+
+func (s *TestSuiteLifecycle) TestHookErrors() {
+	l := NewLifecycle(context.Background())
+	errHook := errors.New("hook failed")
+	l.OnStop(0, "failing", func(context.Context) error { return errHook })
+	l.OnStop(1, "panicking", func(context.Context) error { panic("hook panic") })
+
+	err := l.Shutdown(context.Background())
+	s.ErrorIs(err, errHook)
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Equal("hook panic", pe.Value)
+	s.Contains(err.Error(), `stop hook "panicking"`)
+}
+
+func (s *TestSuiteLifecycle) TestReportsLeakedGoroutines() {
+	l := NewLifecycle(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	s.stubbornCaller(l, release)
+	l.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := l.Shutdown(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	var se *ShutdownError
+	s.Require().ErrorAs(err, &se)
+	s.Require().Len(se.Leaked, 1)
+	s.Equal("stubborn", se.Leaked[0].Name)
+	s.NotZero(se.Leaked[0].GoroutineID)
+	s.T().Logf("shutdown error:\n%s", err.Error())
+	s.Contains(err.Error(), "1 goroutines did not exit")
+	s.Contains(err.Error(), "stubbornCaller")
+}
+
+func (s *TestSuiteLifecycle) TestGoAfterShutdown() {
+	l := NewLifecycle(context.Background())
+	s.NoError(l.Shutdown(context.Background()))
+
+	errCh := make(chan error, 1)
+	l.Go(func(context.Context) error {
+		s.Fail("should not run")
+		return nil
+	}, WithErrorHandler(func(err error) { errCh <- err }))
+	s.ErrorIs(<-errCh, ErrLifecycleStopped)
+}
+
+func (s *TestSuiteLifecycle) TestErrorsGoToHandler() {
+	errCh := make(chan error, 1)
+	l := NewLifecycle(context.Background(), WithLifecycleOptions(WithErrorHandler(func(err error) { errCh <- err })))
+	defer l.Shutdown(context.Background())
+
+	errWorker := errors.New("worker failed")
+	l.Go(func(context.Context) error { return errWorker })
+	s.ErrorIs(<-errCh, errWorker)
+}
+
+func (s *TestSuiteLifecycle) TestParentCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewLifecycle(ctx)
+	stopped := make(chan struct{})
+	l.OnStop(0, "hook", func(context.Context) error { close(stopped); return nil })
+
+	cancel()
+	select {
+	case <-l.Done():
+	case <-time.After(time.Second):
+		s.FailNow("parent cancellation did not shut down")
+	}
+	<-stopped
+	s.NoError(l.Wait())
+}
+
+func (s *TestSuiteLifecycle) stubbornCaller(l *Lifecycle, release chan struct{}) {
+	l.Go(func(context.Context) error {
+		<-release
+		return nil
+	}, WithName("stubborn"))
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package routine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+func (s *TestSuiteLifecycle) TestSignal() {
+	l := NewLifecycle(context.Background(), WithSignals(syscall.SIGUSR1), WithShutdownTimeout(time.Second))
+	l.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	p, err := os.FindProcess(os.Getpid())
+	s.Require().NoError(err)
+	s.Require().NoError(p.Signal(syscall.SIGUSR1))
+	select {
+	case <-l.Done():
+	case <-time.After(time.Second):
+		s.FailNow("signal did not shut down")
+	}
+	s.NoError(l.Wait())
+}
+
+func (s *TestSuiteLifecycle) TestSecondSignalNotCaught() {
+	if os.Getenv("ROUTINE_TEST_SECOND_SIGNAL") != "" {
+		// child process: a stop hook stuck after the first signal sends the
+		// second one, which must kill the process
+		l := NewLifecycle(context.Background(), WithSignals(syscall.SIGTERM), WithShutdownTimeout(time.Minute))
+		l.OnStop(0, "stuck", func(context.Context) error {
+			syscall.Kill(os.Getpid(), syscall.SIGTERM)
+			time.Sleep(5 * time.Second)
+			return nil
+		})
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		<-l.Done()
+		os.Exit(0)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLifecycle$/^TestSecondSignalNotCaught$")
+	cmd.Env = append(os.Environ(), "ROUTINE_TEST_SECOND_SIGNAL=1")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	s.Require().ErrorAs(err, &exitErr)
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	s.Require().True(ok)
+	s.True(status.Signaled(), "the process survived the second signal")
+	s.Equal(syscall.SIGTERM, status.Signal())
+}
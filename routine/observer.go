@@ -0,0 +1,143 @@
+package routine
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives lifecycle events of functions wrapped by Recover, which
+// includes every goroutine launched by Go, Group and Pool.
+// Implementations must be safe for concurrent use and should return quickly.
+type Observer interface {
+	// OnStart is called right before the wrapped function runs.
+	OnStart(name string)
+	// OnFinish is called after the wrapped function returns or panics.
+	// err is the returned error, or the *PanicError after a panic.
+	OnFinish(name string, d time.Duration, err error)
+	// OnPanic is called after a panic is recovered, before OnFinish.
+	OnPanic(name string, err *PanicError)
+}
+
+var (
+	observersMu sync.Mutex
+	observers   atomic.Pointer[[]Observer]
+)
+
+// RegisterObserver adds o to the global observers notified for every call,
+// and returns a function that removes it again.
+func RegisterObserver(o Observer) (unregister func()) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+	next := append(globalObservers(), o)
+	observers.Store(&next)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			observersMu.Lock()
+			defer observersMu.Unlock()
+			cur := globalObservers()
+			if i := slices.Index(cur, o); i >= 0 {
+				next := slices.Delete(slices.Clone(cur), i, i+1)
+				observers.Store(&next)
+			}
+		})
+	}
+}
+
+func globalObservers() []Observer {
+	if p := observers.Load(); p != nil {
+		return slices.Clip(*p)
+	}
+	return nil
+}
+
+// observersFor merges the global observers with the ones from cfg.
+func observersFor(cfg *config) []Observer {
+	global := globalObservers()
+	if len(cfg.observers) == 0 {
+		return global
+	}
+	return append(global, cfg.observers...)
+}
+
+// CountingObserver is an in-memory Observer that counts events per name.
+type CountingObserver struct {
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+// Stats are the counters kept by CountingObserver for one name.
+type Stats struct {
+	Started  int64
+	Finished int64
+	Failed   int64 // finished with a non-nil error, including panics
+	Panicked int64
+	Running  int64
+	Duration time.Duration // total run time of finished calls
+}
+
+// NewCountingObserver returns an empty CountingObserver.
+func NewCountingObserver() *CountingObserver {
+	return &CountingObserver{stats: make(map[string]*Stats)}
+}
+
+// Stats returns a copy of the counters for name.
+func (o *CountingObserver) Stats(name string) Stats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if st, ok := o.stats[name]; ok {
+		return *st
+	}
+	return Stats{}
+}
+
+// All returns a copy of the counters of every observed name.
+func (o *CountingObserver) All() map[string]Stats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make(map[string]Stats, len(o.stats))
+	for name, st := range o.stats {
+		out[name] = *st
+	}
+	return out
+}
+
+// OnStart counts a started call as running.
+func (o *CountingObserver) OnStart(name string) {
+	o.update(name, func(st *Stats) {
+		st.Started++
+		st.Running++
+	})
+}
+
+// OnFinish counts a finished call, failed if err is not nil, and adds d to
+// the total duration.
+func (o *CountingObserver) OnFinish(name string, d time.Duration, err error) {
+	o.update(name, func(st *Stats) {
+		st.Finished++
+		st.Running--
+		st.Duration += d
+		if err != nil {
+			st.Failed++
+		}
+	})
+}
+
+// OnPanic counts a panic; OnFinish still follows it.
+func (o *CountingObserver) OnPanic(name string, _ *PanicError) {
+	o.update(name, func(st *Stats) { st.Panicked++ })
+}
+
+func (o *CountingObserver) update(name string, fn func(*Stats)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st, ok := o.stats[name]
+	if !ok {
+		st = new(Stats)
+		o.stats[name] = st
+	}
+	fn(st)
+}
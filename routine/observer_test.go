@@ -0,0 +1,75 @@
+package routine
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestObserver(t *testing.T) {
+	suite.Run(t, new(TestSuiteObserver))
+}
+
+type TestSuiteObserver struct {
+	suite.Suite
+}
+
+func (s *TestSuiteObserver) TestCountingObserver() {
+	o := NewCountingObserver()
+
+	s.NoError(Recover(func() error { return nil }, WithName("ok"), WithObserver(o))())
+	s.Error(Recover(func() error { return errors.New("failed") }, WithName("ok"), WithObserver(o))())
+	s.Error(Recover(func() error { panic("boom") }, WithName("panic"), WithObserver(o))())
+
+	ok := o.Stats("ok")
+	s.Equal(int64(2), ok.Started)
+	s.Equal(int64(2), ok.Finished)
+	s.Equal(int64(1), ok.Failed)
+	s.Zero(ok.Panicked)
+	s.Zero(ok.Running)
+
+	panicked := o.Stats("panic")
+	s.Equal(int64(1), panicked.Panicked)
+	s.Equal(int64(1), panicked.Failed)
+	s.Len(o.All(), 2)
+}
+
+func (s *TestSuiteObserver) TestRegisterObserver() {
+	o := NewCountingObserver()
+	unregister := RegisterObserver(o)
+
+	done := make(chan struct{})
+	Go(func() { close(done) }, WithName("global"))
+	<-done
+	s.Eventually(func() bool { return o.Stats("global").Finished == 1 }, time.Second, 10*time.Millisecond)
+
+	unregister()
+	unregister()
+	s.NoError(Recover(func() error { return nil }, WithName("global"))())
+	s.Equal(int64(1), o.Stats("global").Started)
+}
+
+func (s *TestSuiteObserver) TestExpvarObserver() {
+	o := NewExpvarObserver("routine_test")
+	s.Same(o.vars, NewExpvarObserver("routine_test").vars)
+
+	before := s.expvarCounters("routine_test", "job")
+	s.Error(Recover(func() error { panic("boom") }, WithName("job"), WithObserver(o))())
+	after := s.expvarCounters("routine_test", "job")
+
+	s.Equal(int64(1), after["started"]-before["started"])
+	s.Equal(int64(1), after["finished"]-before["finished"])
+	s.Equal(int64(1), after["failed"]-before["failed"])
+	s.Equal(int64(1), after["panicked"]-before["panicked"])
+	s.Zero(after["running"])
+}
+
+func (s *TestSuiteObserver) expvarCounters(varName, name string) map[string]int64 {
+	var got map[string]map[string]int64
+	s.Require().NoError(json.Unmarshal([]byte(expvar.Get(varName).String()), &got))
+	return got[name]
+}
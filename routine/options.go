@@ -1,5 +1,12 @@
 package routine
 
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
 // Option configures Wrap behavior.
 type Option func(*config)
 
@@ -8,6 +15,19 @@ type config struct {
 	noCallerStack bool
 	noPanicStack  bool
 	errorHandler  func(error)
+	name          string
+	observers     []Observer
+	timeout       time.Duration
+	deadline      time.Time
+	leakGrace     time.Duration
+	stallBudget   time.Duration
+	stallRepeat   time.Duration
+	stack         stackConfig
+	collectErrors bool
+	clock         Clock
+	jitter        time.Duration
+	immediate     bool
+	overlap       OverlapPolicy
 }
 
 // WithCallerSkip adds additional frames to skip when capturing the caller
@@ -40,3 +60,180 @@ func WithErrorHandler(fn func(error)) Option {
 		c.errorHandler = fn
 	}
 }
+
+// WithName labels the goroutine for observers, e.g. as a metrics dimension.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithObserver adds an observer for this call, in addition to the ones
+// registered globally with RegisterObserver.
+func WithObserver(o Observer) Option {
+	return func(c *config) {
+		c.observers = append(c.observers, o)
+	}
+}
+
+// WithTimeout bounds the context passed to the function launched by GoCtx.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithDeadline sets a deadline on the context passed to the function
+// launched by GoCtx.
+func WithDeadline(t time.Time) Option {
+	return func(c *config) {
+		c.deadline = t
+	}
+}
+
+// WithLeakGrace makes GoCtx report a *LeakError to the error handler when the
+// goroutine is still running d after its context is done. Zero disables it.
+func WithLeakGrace(d time.Duration) Option {
+	return func(c *config) {
+		c.leakGrace = d
+	}
+}
+
+// WithWatchdog makes Go and GoCtx report a *StallError when the goroutine is
+// still running after budget, then again every repeat until it returns.
+// A zero repeat reports only once; a zero budget disables the watchdog.
+func WithWatchdog(budget, repeat time.Duration) Option {
+	return func(c *config) {
+		c.stallBudget = budget
+		c.stallRepeat = repeat
+	}
+}
+
+// WithMaxDepth limits the number of frames captured for the caller and panic
+// stacks. Defaults to 0, which captures the whole stack.
+func WithMaxDepth(n int) Option {
+	return func(c *config) {
+		c.stack.maxDepth = n
+	}
+}
+
+// WithFrameFilter adds filters deciding which frames are kept when stacks
+// are formatted, e.g. DropRuntimeFrames or DropPackages.
+func WithFrameFilter(filters ...FrameFilter) Option {
+	return func(c *config) {
+		c.stack.filters = append(c.stack.filters, filters...)
+	}
+}
+
+// WithTrimPathPrefix trims the first matching prefix from file paths of
+// formatted frames, e.g. the module root or $GOPATH/pkg/mod/.
+func WithTrimPathPrefix(prefixes ...string) Option {
+	return func(c *config) {
+		c.stack.trimPrefixes = append(c.stack.trimPrefixes, prefixes...)
+	}
+}
+
+// WithCollapseRecursion collapses consecutive frames of the same function,
+// as produced by deep recursion, into one frame with a repeat count.
+func WithCollapseRecursion(on bool) Option {
+	return func(c *config) {
+		c.stack.collapse = on
+	}
+}
+
+// WithCollectErrors makes ParallelMap and ForEach run every item and return
+// all errors joined, instead of failing fast on the first error.
+func WithCollectErrors(on bool) Option {
+	return func(c *config) {
+		c.collectErrors = on
+	}
+}
+
+// WithClock sets the clock used by scheduled tasks, Retry delays and
+// Supervisor restarts, e.g. a FakeClock in tests. Defaults to SystemClock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithJitter delays every activation of a scheduled task by a random
+// duration in [0, d), to spread load across instances.
+func WithJitter(d time.Duration) Option {
+	return func(c *config) {
+		c.jitter = d
+	}
+}
+
+// WithImmediate makes a scheduled task run once right away, before its
+// first activation.
+func WithImmediate(on bool) Option {
+	return func(c *config) {
+		c.immediate = on
+	}
+}
+
+// WithOverlap sets what a scheduled task does with activations due while
+// the previous run is still going. Defaults to OverlapSkip.
+func WithOverlap(p OverlapPolicy) Option {
+	return func(c *config) {
+		c.overlap = p
+	}
+}
+
+func newConfig(options []Option) config {
+	var cfg config
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// timer returns the configured clock, or SystemClock.
+func (c *config) timer() Clock {
+	if c.clock == nil {
+		return SystemClock
+	}
+	return c.clock
+}
+
+// handler returns the configured error handler, or the default one set by
+// SetDefaultErrorHandler.
+func (c *config) handler() func(error) {
+	if c.errorHandler != nil {
+		return c.errorHandler
+	}
+	return defaultErrorHandler
+}
+
+var defaultHandler atomic.Pointer[func(error)]
+
+// SetDefaultErrorHandler sets the error handler used when no WithErrorHandler
+// option is given, including by goroutines already launched and by libraries
+// built on this package. A nil fn restores the initial handler, which writes
+// errors to stderr.
+func SetDefaultErrorHandler(fn func(error)) {
+	if fn == nil {
+		defaultHandler.Store(nil)
+		return
+	}
+	defaultHandler.Store(&fn)
+}
+
+func defaultErrorHandler(err error) {
+	if fn := defaultHandler.Load(); fn != nil {
+		(*fn)(err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, err.Error())
+}
+
+// callerStack captures the caller stack for an exported entry point that
+// calls it directly, honoring WithCallerStack and WithCallerSkip.
+func (c *config) callerStack() []uintptr {
+	if c.noCallerStack {
+		return nil
+	}
+	// skip: callerStack + entry point + user-requested extra
+	return callers(2+c.callerSkip, c.stack.maxDepth)
+}
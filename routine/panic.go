@@ -5,10 +5,12 @@ import "fmt"
 // Panic triggers an unrecoverable panic that cannot be caught by any recover().
 // It panics in a separate goroutine where no deferred recover can intercept it,
 // and blocks the caller so execution cannot continue.
-// The panic message includes the caller's stack trace for diagnostics.
-func Panic(v any) {
-	// skip: captureStack + Panic
-	stack := captureStack(1)
+// The panic message includes the caller's stack trace for diagnostics,
+// formatted according to the stack options such as WithFrameFilter.
+func Panic(v any, options ...Option) {
+	cfg := newConfig(options)
+	// skip: Panic + user-requested extra
+	stack := cfg.stack.format(callers(1+cfg.callerSkip, cfg.stack.maxDepth))
 
 	go func() {
 		panic(fmt.Sprintf("%v\n\n%s", v, stack))
@@ -0,0 +1,84 @@
+package routine
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// PanicError is the error returned by a function wrapped with Recover when
+// it panics. It keeps the panic value and both stacks, so callers can inspect
+// them with errors.As, and errors.Is/As reach the panicked error.
+type PanicError struct {
+	// Name is the goroutine name set by WithName.
+	Name string
+	// Value is the value passed to panic.
+	Value any
+	// Err is Value if it is an error, otherwise Value formatted with %+v.
+	Err error
+	// PanicStack holds the program counters of the panicking goroutine,
+	// starting at the frame that panicked. Empty with WithPanicStack(false).
+	PanicStack []uintptr
+	// CallerStack holds the program counters of the call site of Recover.
+	// Empty with WithCallerStack(false).
+	CallerStack []uintptr
+	// Original is the error the wrapped function had already returned, if any.
+	Original error
+
+	stack *stackConfig
+}
+
+func newPanicError(r any) *PanicError {
+	e := &PanicError{Value: r}
+	if err, ok := r.(error); ok {
+		e.Err = err
+	} else {
+		e.Err = fmt.Errorf("%+v", r)
+	}
+	return e
+}
+
+// Error renders the panic value, the panic stack, the caller stack and the
+// original error.
+func (e *PanicError) Error() string {
+	msg := fmt.Appendf(nil, "panic: %s", e.Err)
+	if len(e.PanicStack) > 0 {
+		msg = fmt.Appendf(msg, "\n\n%s", e.stack.format(e.PanicStack))
+	}
+	if len(e.CallerStack) > 0 {
+		msg = fmt.Appendf(msg, "\ncalled from:\n\n%s", e.stack.format(e.CallerStack))
+	}
+	if e.Original != nil {
+		msg = fmt.Appendf(msg, "\noriginal error: %+v", e.Original)
+	}
+	return string(msg)
+}
+
+// Unwrap returns the panicked error and the original error, if any.
+func (e *PanicError) Unwrap() []error {
+	if e.Original == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Original}
+}
+
+// PanicFrames returns the frames of PanicStack, innermost first, after
+// applying the frame filters and path trimming configured for Recover.
+func (e *PanicError) PanicFrames() []runtime.Frame { return e.stack.runtimeFrames(e.PanicStack) }
+
+// CallerFrames returns the frames of CallerStack like PanicFrames.
+func (e *PanicError) CallerFrames() []runtime.Frame { return e.stack.runtimeFrames(e.CallerStack) }
+
+// Format implements fmt.Formatter. %v, %+v and %s print Error, stacks
+// included; %q prints it quoted. Other verbs are reported as bad verbs, as
+// fmt does.
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(PanicError=%s)", verb, e.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package routine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPanicError(t *testing.T) {
+	suite.Run(t, new(TestSuitePanicError))
+}
+
+type TestSuitePanicError struct {
+	suite.Suite
+}
+
+var errSentinel = errors.New("sentinel")
+
+func (s *TestSuitePanicError) TestErrorsAs() {
+	err := Recover(func() error { panic("boom") })()
+
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Equal("boom", pe.Value)
+	s.EqualError(pe.Err, "boom")
+	s.NotEmpty(pe.PanicStack)
+	s.NotEmpty(pe.CallerStack)
+	s.Nil(pe.Original)
+}
+
+func (s *TestSuitePanicError) TestErrorsIsPanickedError() {
+	err := Recover(func() error { panic(fmt.Errorf("wrapped: %w", errSentinel)) })()
+	s.ErrorIs(err, errSentinel)
+}
+
+func (s *TestSuitePanicError) TestFrames() {
+	err := Recover(func() error { panicTopFrame(); return nil })()
+
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	panicFrames := pe.PanicFrames()
+	s.Require().NotEmpty(panicFrames)
+	s.True(strings.HasSuffix(panicFrames[0].Function, ".panicTopFrame"), panicFrames[0].Function)
+
+	callerFrames := pe.CallerFrames()
+	s.Require().NotEmpty(callerFrames)
+	s.True(strings.HasSuffix(callerFrames[0].Function, ".TestFrames"), callerFrames[0].Function)
+}
+
+func (s *TestSuitePanicError) TestFormat() {
+	err := Recover(func() error { panic("boom") })()
+
+	s.Equal(err.Error(), fmt.Sprintf("%v", err))
+	s.Equal(err.Error(), fmt.Sprintf("%+v", err))
+	s.True(strings.HasPrefix(err.Error(), "panic: boom\n\n"))
+	s.Contains(err.Error(), "\ncalled from:\n\n")
+	s.Contains(err.Error(), "panic_error_test.go")
+	s.Equal(fmt.Sprintf("%q", err.Error()), fmt.Sprintf("%q", err))
+	s.Equal("%!d(PanicError="+err.Error()+")", fmt.Sprintf("%d", err))
+}
+
+func (s *TestSuitePanicError) TestOriginalError() {
+	pe := &PanicError{Err: errors.New("boom"), Original: errSentinel}
+	s.Equal("panic: boom\noriginal error: sentinel", pe.Error())
+	s.ErrorIs(pe, errSentinel)
+}
+
+func (s *TestSuitePanicError) TestWithoutStacks() {
+	err := Recover(func() error { panic("boom") }, WithPanicStack(false), WithCallerStack(false))()
+	s.Equal("panic: boom", err.Error())
+}
+
+func panicTopFrame() { panic("top") }
@@ -51,3 +51,21 @@ func (s *TestSuitePanic) TestPanicCannotBeRecovered() {
 	s.Contains(output, "should not be recoverable")
 	s.T().Logf("panic output (with recover attempt):\n%s", output)
 }
+
+func (s *TestSuitePanic) TestPanicWithFrameFilter() {
+	if os.Getenv("TEST_FORCE_PANIC") == "1" {
+		Panic("filtered panic test", WithFrameFilter(DropPackages("github.com/stretchr/testify/")))
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestPanic/TestPanicWithFrameFilter")
+	cmd.Env = append(os.Environ(), "TEST_FORCE_PANIC=1")
+	out, err := cmd.CombinedOutput()
+
+	s.Error(err, "process should have exited with non-zero status")
+	output := string(out)
+	s.Contains(output, "filtered panic test")
+	s.Contains(output, "panic_test.go")
+	s.NotContains(output, "stretchr/testify")
+	s.T().Logf("panic output:\n%s", output)
+}
@@ -0,0 +1,126 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// ParallelMap calls fn for every item with at most limit calls running at
+// once, and returns the results in the order of items. A limit <= 0 means
+// no limit. Every call is wrapped with Recover.
+//
+// By default the first error or panic cancels the context passed to the
+// remaining calls, no new calls are started, and that error is returned.
+// With WithCollectErrors(true), every item runs and all errors are returned
+// joined. The results of failed items are left as zero values.
+func ParallelMap[T, R any](ctx context.Context, items []T, limit int, fn func(context.Context, T) (R, error), options ...Option) ([]R, error) {
+	cfg := newConfig(options)
+	results, err := parallelMap(ctx, slices.Values(items), limit, fn, &cfg, cfg.callerStack())
+	// items not started after failing fast still get a zero value
+	return append(results, make([]R, len(items)-len(results))...), err
+}
+
+// ParallelMapSeq is ParallelMap over an iterator. The iterator is consumed
+// from the calling goroutine as concurrency allows.
+func ParallelMapSeq[T, R any](ctx context.Context, seq iter.Seq[T], limit int, fn func(context.Context, T) (R, error), options ...Option) ([]R, error) {
+	cfg := newConfig(options)
+	return parallelMap(ctx, seq, limit, fn, &cfg, cfg.callerStack())
+}
+
+// ForEach is ParallelMap for functions without results.
+func ForEach[T any](ctx context.Context, items []T, limit int, fn func(context.Context, T) error, options ...Option) error {
+	cfg := newConfig(options)
+	_, err := parallelMap(ctx, slices.Values(items), limit, discardResult(fn), &cfg, cfg.callerStack())
+	return err
+}
+
+// ForEachSeq is ForEach over an iterator.
+func ForEachSeq[T any](ctx context.Context, seq iter.Seq[T], limit int, fn func(context.Context, T) error, options ...Option) error {
+	cfg := newConfig(options)
+	_, err := parallelMap(ctx, seq, limit, discardResult(fn), &cfg, cfg.callerStack())
+	return err
+}
+
+func discardResult[T any](fn func(context.Context, T) error) func(context.Context, T) (struct{}, error) {
+	return func(ctx context.Context, item T) (struct{}, error) { return struct{}{}, fn(ctx, item) }
+}
+
+func parallelMap[T, R any](
+	ctx context.Context,
+	seq iter.Seq[T],
+	limit int,
+	fn func(context.Context, T) (R, error),
+	cfg *config,
+	callerFrames []uintptr,
+) ([]R, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []R
+		errs    []error
+	)
+	for item := range seq {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		mu.Lock()
+		i := len(results)
+		results = append(results, *new(R))
+		mu.Unlock()
+
+		run := recoverWith(cfg, callerFrames, func() error {
+			r, err := fn(ctx, item)
+			if err == nil {
+				mu.Lock()
+				results[i] = r
+				mu.Unlock()
+			}
+			return err
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			if err := run(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				if !cfg.collectErrors {
+					cancel(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		if cfg.collectErrors {
+			return results, errors.Join(errs...)
+		}
+		return results, errs[0]
+	}
+	if err := context.Cause(ctx); err != nil {
+		return results, err
+	}
+	return results, nil
+}
@@ -0,0 +1,121 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestParallel(t *testing.T) {
+	suite.Run(t, new(TestSuiteParallel))
+}
+
+type TestSuiteParallel struct {
+	suite.Suite
+}
+
+func (s *TestSuiteParallel) TestParallelMapPreservesOrder() {
+	items := []int{5, 4, 3, 2, 1}
+	results, err := ParallelMap(context.Background(), items, 2, func(_ context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(v) * time.Millisecond)
+		return v * 10, nil
+	})
+	s.NoError(err)
+	s.Equal([]int{50, 40, 30, 20, 10}, results)
+}
+
+func (s *TestSuiteParallel) TestLimit() {
+	var running, peak atomic.Int32
+	err := ForEach(context.Background(), make([]int, 20), 3, func(context.Context, int) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	s.NoError(err)
+	s.LessOrEqual(peak.Load(), int32(3))
+}
+
+func (s *TestSuiteParallel) TestFailFast() {
+	var started atomic.Int32
+	errBad := errors.New("bad item")
+	results, err := ParallelMap(context.Background(), []int{1, 2, 3, 4, 5, 6}, 1, func(ctx context.Context, v int) (int, error) {
+		started.Add(1)
+		if v == 2 {
+			return 0, errBad
+		}
+		return v, nil
+	})
+	s.ErrorIs(err, errBad)
+	s.Len(results, 6)
+	s.Equal(1, results[0])
+	s.Less(started.Load(), int32(6))
+}
+
+func (s *TestSuiteParallel) TestCollectErrors() {
+	errOdd := errors.New("odd")
+	results, err := ParallelMap(context.Background(), []int{1, 2, 3, 4}, 0, func(_ context.Context, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v, nil
+	}, WithCollectErrors(true))
+	s.ErrorIs(err, errOdd)
+	s.Len(err.(interface{ Unwrap() []error }).Unwrap(), 2)
+	s.Equal([]int{0, 2, 0, 4}, results)
+}
+
+func (s *TestSuiteParallel) TestPanicRecovered() {
+	err := ForEach(context.Background(), []int{1, 2, 3}, 0, func(_ context.Context, v int) error {
+		if v == 2 {
+			panic("item panic")
+		}
+		return nil
+	})
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Contains(err.Error(), "parallel_test.go")
+
+	var callerFound bool
+	for _, f := range pe.CallerFrames() {
+		if f.Function == "github.com/yeluyang/gopkg/routine.(*TestSuiteParallel).TestPanicRecovered" {
+			callerFound = true
+		}
+	}
+	s.True(callerFound, "caller stack should start at the ForEach call site")
+}
+
+func (s *TestSuiteParallel) TestContextCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var called atomic.Bool
+	err := ForEach(ctx, []int{1, 2, 3}, 1, func(context.Context, int) error {
+		called.Store(true)
+		return nil
+	})
+	s.ErrorIs(err, context.Canceled)
+	s.False(called.Load())
+}
+
+func (s *TestSuiteParallel) TestSeq() {
+	results, err := ParallelMapSeq(context.Background(), slices.Values([]string{"a", "b", "c"}), 2,
+		func(_ context.Context, v string) (string, error) { return v + v, nil })
+	s.NoError(err)
+	s.Equal([]string{"aa", "bb", "cc"}, results)
+
+	var sum atomic.Int32
+	s.NoError(ForEachSeq(context.Background(), slices.Values([]int32{1, 2, 3}), 0,
+		func(_ context.Context, v int32) error { sum.Add(v); return nil }))
+	s.Equal(int32(6), sum.Load())
+}
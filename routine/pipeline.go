@@ -0,0 +1,255 @@
+package routine
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+	"time"
+)
+
+// Pipeline connects stages running in their own goroutines through typed
+// channels. Stages share the pipeline's context: the first stage returning
+// an error or panicking cancels it, which tears down every other stage, and
+// its error is returned by Wait. Each stage closes its output channels when
+// it returns.
+//
+//	p := routine.NewPipeline(ctx)
+//	ids := routine.SourceSeq(p, slices.Values(input))
+//	users := routine.Transform(p, ids, 8, fetchUser)
+//	routine.Sink(p, routine.Batch(p, users, 100, time.Second), saveUsers)
+//	err := p.Wait()
+type Pipeline struct {
+	g   *Group
+	ctx context.Context
+}
+
+// NewPipeline returns a pipeline whose context derives from ctx. The options
+// apply to every stage and can be overridden per stage.
+func NewPipeline(ctx context.Context, options ...Option) *Pipeline {
+	g, ctx := WithContext(ctx, options...)
+	return &Pipeline{g: g, ctx: ctx}
+}
+
+// Context returns the context shared by the stages.
+func (p *Pipeline) Context() context.Context { return p.ctx }
+
+// Wait blocks until every stage has returned, then returns the first error
+// or recovered panic. It returns the context error if ctx passed to
+// NewPipeline was canceled.
+func (p *Pipeline) Wait() error { return p.g.Wait() }
+
+// goStage runs fn as a member of the pipeline's group. It must be called
+// directly from an exported stage function, so the caller stack points to
+// where the stage was added.
+func (p *Pipeline) goStage(fn func() error, options []Option) {
+	// WithCallerSkip(3) skips Group.Go, goStage and the stage function
+	p.g.Go(fn, append([]Option{WithCallerSkip(3)}, options...)...)
+}
+
+// Source starts a stage producing values with fn, which passes each value to
+// emit. emit blocks until the value is consumed and returns the context
+// error once the pipeline is torn down, which fn should return.
+func Source[T any](p *Pipeline, fn func(ctx context.Context, emit func(T) error) error, options ...Option) <-chan T {
+	out := make(chan T)
+	p.goStage(func() error {
+		defer close(out)
+		return fn(p.ctx, func(v T) error { return send(p.ctx, out, v) })
+	}, options)
+	return out
+}
+
+// SourceSeq starts a stage producing the values of seq.
+func SourceSeq[T any](p *Pipeline, seq iter.Seq[T], options ...Option) <-chan T {
+	out := make(chan T)
+	p.goStage(func() error {
+		defer close(out)
+		for v := range seq {
+			if err := send(p.ctx, out, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, options)
+	return out
+}
+
+// Transform starts a stage applying fn to every value of in on the given
+// number of workers (at least one). With more than one worker, output order
+// is not preserved. It is the pipeline counterpart of Map.
+func Transform[T, R any](p *Pipeline, in <-chan T, workers int, fn func(context.Context, T) (R, error), options ...Option) <-chan R {
+	out := make(chan R)
+	workers = max(workers, 1)
+	var remaining atomic.Int32
+	remaining.Store(int32(workers))
+	for range workers {
+		p.goStage(func() error {
+			defer func() {
+				// the last worker to return closes the output
+				if remaining.Add(-1) == 0 {
+					close(out)
+				}
+			}()
+			return receive(p.ctx, in, func(v T) error {
+				r, err := fn(p.ctx, v)
+				if err != nil {
+					return err
+				}
+				return send(p.ctx, out, r)
+			})
+		}, options)
+	}
+	return out
+}
+
+// Filter starts a stage passing on the values of in for which keep returns
+// true.
+func Filter[T any](p *Pipeline, in <-chan T, keep func(T) bool, options ...Option) <-chan T {
+	out := make(chan T)
+	p.goStage(func() error {
+		defer close(out)
+		return receive(p.ctx, in, func(v T) error {
+			if !keep(v) {
+				return nil
+			}
+			return send(p.ctx, out, v)
+		})
+	}, options)
+	return out
+}
+
+// Batch starts a stage grouping the values of in into slices of up to size
+// values. With a positive maxWait, a partial batch is also emitted maxWait
+// after its first value arrived. The last partial batch is emitted when in
+// is closed.
+func Batch[T any](p *Pipeline, in <-chan T, size int, maxWait time.Duration, options ...Option) <-chan []T {
+	out := make(chan []T)
+	size = max(size, 1)
+	p.goStage(func() error {
+		defer close(out)
+		var batch []T
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		flush := func() error {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			b := batch
+			batch = nil
+			return send(p.ctx, out, b)
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return flush()
+				}
+				batch = append(batch, v)
+				if len(batch) >= size {
+					if err := flush(); err != nil {
+						return err
+					}
+				} else if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					timeout = timer.C
+				}
+			case <-timeout:
+				if err := flush(); err != nil {
+					return err
+				}
+			case <-p.ctx.Done():
+				return p.ctx.Err()
+			}
+		}
+	}, options)
+	return out
+}
+
+// Merge starts a stage forwarding the values of every input to a single
+// output, in no particular order.
+func Merge[T any](p *Pipeline, ins []<-chan T, options ...Option) <-chan T {
+	out := make(chan T)
+	if len(ins) == 0 {
+		close(out)
+		return out
+	}
+	var remaining atomic.Int32
+	remaining.Store(int32(len(ins)))
+	for _, in := range ins {
+		p.goStage(func() error {
+			defer func() {
+				if remaining.Add(-1) == 0 {
+					close(out)
+				}
+			}()
+			return receive(p.ctx, in, func(v T) error { return send(p.ctx, out, v) })
+		}, options)
+	}
+	return out
+}
+
+// Tee starts a stage copying every value of in to n outputs. Each value is
+// sent to all outputs before the next one is read, so the slowest consumer
+// sets the pace.
+func Tee[T any](p *Pipeline, in <-chan T, n int, options ...Option) []<-chan T {
+	outs := make([]chan T, n)
+	ros := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		ros[i] = outs[i]
+	}
+	p.goStage(func() error {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		return receive(p.ctx, in, func(v T) error {
+			for _, out := range outs {
+				if err := send(p.ctx, out, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, options)
+	return ros
+}
+
+// Sink starts a stage consuming the values of in with fn.
+func Sink[T any](p *Pipeline, in <-chan T, fn func(context.Context, T) error, options ...Option) {
+	p.goStage(func() error {
+		return receive(p.ctx, in, func(v T) error { return fn(p.ctx, v) })
+	}, options)
+}
+
+// send sends v on out unless ctx is done first.
+func send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// receive calls fn for every value of in until in is closed, fn fails or ctx
+// is done.
+func receive[T any](ctx context.Context, in <-chan T, fn func(T) error) error {
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return nil
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPipeline(t *testing.T) {
+	suite.Run(t, new(TestSuitePipeline))
+}
+
+type TestSuitePipeline struct {
+	suite.Suite
+}
+
+func (s *TestSuitePipeline) TestStages() {
+	p := NewPipeline(context.Background())
+	nums := SourceSeq(p, slices.Values([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}))
+	even := Filter(p, nums, func(n int) bool { return n%2 == 0 })
+	squares := Transform(p, even, 3, func(_ context.Context, n int) (int, error) { return n * n, nil })
+	batches := Batch(p, squares, 2, 0)
+
+	var got []int
+	Sink(p, batches, func(_ context.Context, b []int) error {
+		s.LessOrEqual(len(b), 2)
+		got = append(got, b...)
+		return nil
+	})
+	s.Require().NoError(p.Wait())
+	slices.Sort(got)
+	s.Equal([]int{4, 16, 36, 64, 100}, got)
+}
+
+func (s *TestSuitePipeline) TestMergeAndTee() {
+	p := NewPipeline(context.Background())
+	a := SourceSeq(p, slices.Values([]int{1, 2, 3}))
+	b := Source(p, func(_ context.Context, emit func(int) error) error {
+		for _, n := range []int{10, 20} {
+			if err := emit(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	outs := Tee(p, Merge(p, []<-chan int{a, b}), 2)
+
+	var mu sync.Mutex
+	sums := make([]int, 2)
+	for i, out := range outs {
+		Sink(p, out, func(_ context.Context, n int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sums[i] += n
+			return nil
+		})
+	}
+	s.Require().NoError(p.Wait())
+	s.Equal([]int{36, 36}, sums)
+}
+
+func (s *TestSuitePipeline) TestBatchMaxWait() {
+	p := NewPipeline(context.Background())
+	in := Source(p, func(_ context.Context, emit func(int) error) error {
+		if err := emit(1); err != nil {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+		return emit(2)
+	})
+
+	var batches [][]int
+	Sink(p, Batch(p, in, 10, 20*time.Millisecond), func(_ context.Context, b []int) error {
+		batches = append(batches, b)
+		return nil
+	})
+	s.Require().NoError(p.Wait())
+	s.Equal([][]int{{1}, {2}}, batches)
+}
+
+func (s *TestSuitePipeline) TestErrorTearsDown() {
+	errSink := errors.New("sink failed")
+	p := NewPipeline(context.Background())
+	infinite := Source(p, func(_ context.Context, emit func(int) error) error {
+		for i := 0; ; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+	})
+	doubled := Transform(p, infinite, 4, func(_ context.Context, n int) (int, error) { return 2 * n, nil })
+	Sink(p, doubled, func(_ context.Context, n int) error {
+		if n > 100 {
+			return errSink
+		}
+		return nil
+	})
+
+	s.ErrorIs(p.Wait(), errSink)
+	s.ErrorIs(context.Cause(p.Context()), errSink)
+}
+
+func (s *TestSuitePipeline) TestPanicTearsDown() {
+	p := NewPipeline(context.Background())
+	in := SourceSeq(p, func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	})
+	s.panickingStage(p, in)
+
+	err := p.Wait()
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Equal("stage panic", pe.Value)
+	s.T().Logf("pipeline error:\n%s", err.Error())
+	s.Contains(err.Error(), "panickingStage")
+}
+
+func (s *TestSuitePipeline) TestParentCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPipeline(ctx)
+	started := make(chan struct{})
+	in := Source(p, func(ctx context.Context, emit func(int) error) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	Sink(p, in, func(context.Context, int) error { return nil })
+
+	<-started
+	cancel()
+	s.ErrorIs(p.Wait(), context.Canceled)
+}
+
+func (s *TestSuitePipeline) panickingStage(p *Pipeline, in <-chan int) {
+	Sink(p, in, func(_ context.Context, n int) error {
+		if n == 10 {
+			panic("stage panic")
+		}
+		return nil
+	})
+}
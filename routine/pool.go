@@ -0,0 +1,272 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrPoolFull is returned by Pool.Submit when the queue is full and the
+	// overflow policy is OverflowReject.
+	ErrPoolFull = errors.New("routine: pool queue is full")
+	// ErrPoolClosed is returned by Pool.Submit after Shutdown has been called.
+	ErrPoolClosed = errors.New("routine: pool is shut down")
+	// ErrTaskDropped is passed to the error handler of a queued task that was
+	// evicted by the OverflowDropOldest policy.
+	ErrTaskDropped = errors.New("routine: task dropped from full pool queue")
+)
+
+// OverflowPolicy decides what Pool.Submit does when the task queue is full
+// and no more workers can be started.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the caller until the queue has room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject returns ErrPoolFull.
+	OverflowReject
+	// OverflowDropOldest evicts the oldest queued task to make room.
+	OverflowDropOldest
+	// OverflowCallerRuns runs the task synchronously in the caller goroutine.
+	OverflowCallerRuns
+)
+
+// PoolOption configures a Pool.
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	maxWorkers  int
+	queueSize   int
+	overflow    OverflowPolicy
+	idleTimeout time.Duration
+	options     []Option
+}
+
+// WithMaxWorkers makes the pool elastic: when the queue is full, extra
+// workers are started up to n in total, and exit after being idle for the
+// idle timeout. n less than the core worker count is ignored.
+func WithMaxWorkers(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.maxWorkers = n
+	}
+}
+
+// WithQueueSize sets the capacity of the task queue. Defaults to 0, which
+// hands tasks directly to idle workers.
+func WithQueueSize(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.queueSize = n
+	}
+}
+
+// WithOverflow sets the policy applied when the queue is full.
+// Defaults to OverflowBlock.
+func WithOverflow(p OverflowPolicy) PoolOption {
+	return func(c *poolConfig) {
+		c.overflow = p
+	}
+}
+
+// WithIdleTimeout sets how long an extra worker started beyond the core
+// worker count waits for a task before exiting. Defaults to one second.
+func WithIdleTimeout(d time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.idleTimeout = d
+	}
+}
+
+// WithTaskOptions sets default Recover options for every submitted task,
+// such as WithErrorHandler. Options passed to Submit follow and override them.
+func WithTaskOptions(options ...Option) PoolOption {
+	return func(c *poolConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// Pool runs submitted tasks on a bounded set of reusable worker goroutines.
+// Every task is wrapped with Recover; panics are reported to the task's
+// error handler and never kill the worker.
+type Pool struct {
+	cfg   poolConfig
+	queue chan poolTask
+
+	workers   atomic.Int32
+	workersWG sync.WaitGroup
+
+	mu         sync.Mutex
+	closed     bool
+	submitting sync.WaitGroup
+}
+
+type poolTask struct {
+	run     func() error
+	handler func(error)
+}
+
+func (t poolTask) exec() {
+	if err := t.run(); err != nil {
+		t.handler(err)
+	}
+}
+
+// NewPool starts a pool with the given number of core workers (at least one).
+// Core workers live until Shutdown.
+func NewPool(workers int, options ...PoolOption) *Pool {
+	cfg := poolConfig{idleTimeout: time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	workers = max(workers, 1)
+	cfg.maxWorkers = max(cfg.maxWorkers, workers)
+
+	p := &Pool{cfg: cfg, queue: make(chan poolTask, cfg.queueSize)}
+	p.workers.Store(int32(workers))
+	p.workersWG.Add(workers)
+	for range workers {
+		go p.coreWorker()
+	}
+	return p
+}
+
+// Submit queues fn for execution on a worker.
+// The caller stack is captured here, so panics point back to the submit site.
+// It returns ErrPoolClosed after Shutdown, and ErrPoolFull when the queue is
+// full under OverflowReject.
+func (p *Pool) Submit(fn func(), options ...Option) error {
+	// WithCallerSkip(1) skips Submit itself; pool and per-call options follow.
+	opts := make([]Option, 0, 1+len(p.cfg.options)+len(options))
+	opts = append(opts, WithCallerSkip(1))
+	opts = append(opts, p.cfg.options...)
+	opts = append(opts, options...)
+	cfg := newConfig(opts)
+	t := poolTask{
+		run:     Recover(func() error { fn(); return nil }, opts...),
+		handler: cfg.handler(),
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	p.submitting.Add(1)
+	p.mu.Unlock()
+	defer p.submitting.Done()
+
+	select {
+	case p.queue <- t:
+		return nil
+	default:
+	}
+	if p.trySpawn(t) {
+		return nil
+	}
+
+	switch p.cfg.overflow {
+	case OverflowReject:
+		return ErrPoolFull
+	case OverflowCallerRuns:
+		t.exec()
+		return nil
+	case OverflowDropOldest:
+		if cap(p.queue) == 0 {
+			// nothing to drop in an unbuffered queue
+			p.queue <- t
+			return nil
+		}
+		for {
+			select {
+			case p.queue <- t:
+				return nil
+			default:
+			}
+			select {
+			case old := <-p.queue:
+				old.handler(ErrTaskDropped)
+			default:
+			}
+		}
+	default:
+		p.queue <- t
+		return nil
+	}
+}
+
+// Workers returns the number of running workers, zero once Shutdown has
+// returned nil.
+func (p *Pool) Workers() int { return int(p.workers.Load()) }
+
+// Shutdown stops accepting new tasks and waits until every queued task has
+// run and all workers have exited, or ctx is done.
+// It is safe to call Shutdown more than once.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		go func() {
+			// close only after in-flight submits are done sending
+			p.submitting.Wait()
+			close(p.queue)
+		}()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workersWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) trySpawn(t poolTask) bool {
+	for {
+		n := p.workers.Load()
+		if int(n) >= p.cfg.maxWorkers {
+			return false
+		}
+		if p.workers.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+	p.workersWG.Add(1)
+	go p.extraWorker(t)
+	return true
+}
+
+func (p *Pool) coreWorker() {
+	defer p.workersWG.Done()
+	defer p.workers.Add(-1)
+	for t := range p.queue {
+		t.exec()
+	}
+}
+
+func (p *Pool) extraWorker(t poolTask) {
+	defer p.workersWG.Done()
+	defer p.workers.Add(-1)
+
+	t.exec()
+	idle := time.NewTimer(p.cfg.idleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case t, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			t.exec()
+			idle.Reset(p.cfg.idleTimeout)
+		case <-idle.C:
+			return
+		}
+	}
+}
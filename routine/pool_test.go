@@ -0,0 +1,132 @@
+package routine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPool(t *testing.T) {
+	suite.Run(t, new(TestSuitePool))
+}
+
+type TestSuitePool struct {
+	suite.Suite
+}
+
+func (s *TestSuitePool) TestSubmitRunsAllTasks() {
+	p := NewPool(4, WithQueueSize(16))
+	var counter atomic.Int32
+	for range 100 {
+		s.Require().NoError(p.Submit(func() { counter.Add(1) }))
+	}
+	s.Require().NoError(p.Shutdown(context.Background()))
+	s.Equal(int32(100), counter.Load())
+}
+
+func (s *TestSuitePool) TestPanicKeepsWorker() {
+	errCh := make(chan error, 1)
+	p := NewPool(1, WithTaskOptions(WithErrorHandler(func(err error) { errCh <- err })))
+	defer p.Shutdown(context.Background())
+
+	s.Require().NoError(p.Submit(func() { panic("pool panic") }))
+	select {
+	case err := <-errCh:
+		s.Contains(err.Error(), "panic: pool panic")
+		s.Contains(err.Error(), "pool_test.go")
+	case <-time.After(time.Second):
+		s.Fail("panic was not reported")
+	}
+
+	done := make(chan struct{})
+	s.Require().NoError(p.Submit(func() { close(done) }))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("worker did not survive the panic")
+	}
+}
+
+func (s *TestSuitePool) TestOverflowReject() {
+	p, release := s.blockedPool(WithOverflow(OverflowReject))
+	s.Require().NoError(p.Submit(func() {}))
+	s.ErrorIs(p.Submit(func() {}), ErrPoolFull)
+	close(release)
+	s.NoError(p.Shutdown(context.Background()))
+	s.Zero(p.Workers())
+}
+
+func (s *TestSuitePool) TestOverflowDropOldest() {
+	dropped := make(chan error, 1)
+	p, release := s.blockedPool(WithOverflow(OverflowDropOldest))
+	s.Require().NoError(p.Submit(func() {}, WithErrorHandler(func(err error) { dropped <- err })))
+
+	var ran atomic.Bool
+	s.Require().NoError(p.Submit(func() { ran.Store(true) }))
+	s.Equal(ErrTaskDropped, <-dropped)
+	close(release)
+	s.NoError(p.Shutdown(context.Background()))
+	s.True(ran.Load())
+}
+
+func (s *TestSuitePool) TestOverflowCallerRuns() {
+	p, release := s.blockedPool(WithOverflow(OverflowCallerRuns))
+	s.Require().NoError(p.Submit(func() {}))
+	var ran bool
+	s.Require().NoError(p.Submit(func() { ran = true }))
+	s.True(ran, "task should have run synchronously")
+	close(release)
+	s.NoError(p.Shutdown(context.Background()))
+}
+
+func (s *TestSuitePool) TestElasticWorkers() {
+	p := NewPool(1, WithMaxWorkers(3), WithIdleTimeout(50*time.Millisecond))
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	for range 3 {
+		s.Require().NoError(p.Submit(func() {
+			wg.Done()
+			<-release
+		}))
+	}
+	wg.Wait()
+	s.Equal(3, p.Workers())
+
+	close(release)
+	s.Eventually(func() bool { return p.Workers() == 1 }, time.Second, 10*time.Millisecond)
+	s.NoError(p.Shutdown(context.Background()))
+}
+
+func (s *TestSuitePool) TestShutdown() {
+	p := NewPool(1)
+	release := make(chan struct{})
+	s.Require().NoError(p.Submit(func() { <-release }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(p.Shutdown(ctx), context.DeadlineExceeded)
+	s.ErrorIs(p.Submit(func() {}), ErrPoolClosed)
+
+	close(release)
+	s.NoError(p.Shutdown(context.Background()))
+}
+
+// blockedPool returns a single-worker pool with a one-slot queue whose worker
+// is blocked until release is closed.
+func (s *TestSuitePool) blockedPool(options ...PoolOption) (*Pool, chan struct{}) {
+	options = append([]PoolOption{WithQueueSize(1)}, options...)
+	p := NewPool(1, options...)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.Require().NoError(p.Submit(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	return p, release
+}
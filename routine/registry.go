@@ -0,0 +1,167 @@
+package routine
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	tracking     atomic.Bool
+	registry     sync.Map // uint64 -> *GoroutineRecord
+	nextRecordID atomic.Uint64
+)
+
+// EnableTracking turns the registry of live goroutines launched by Go on or
+// off. It is off by default since capturing the launch stack costs an extra
+// runtime.Callers per goroutine. Goroutines launched while tracking is off
+// are never registered.
+func EnableTracking(on bool) { tracking.Store(on) }
+
+// GoroutineRecord describes a live goroutine launched by Go while tracking
+// was enabled.
+type GoroutineRecord struct {
+	// ID identifies the record; unique within the process.
+	ID uint64
+	// GoroutineID is the runtime goroutine id, as shown in panics and in
+	// runtime.Stack output. Zero if the goroutine was launched but has not
+	// been scheduled yet.
+	GoroutineID uint64
+	// Name is set by WithName.
+	Name string
+	// Started is when the goroutine was launched.
+	Started time.Time
+	// Stack holds the program counters of the launch site.
+	Stack []uintptr
+}
+
+// Frames returns the frames of the launch stack, innermost first.
+func (r GoroutineRecord) Frames() []runtime.Frame { return callersFrames(r.Stack) }
+
+func newRecord(name string, stack []uintptr) *GoroutineRecord {
+	return &GoroutineRecord{
+		ID:      nextRecordID.Add(1),
+		Name:    name,
+		Started: time.Now(),
+		Stack:   stack,
+	}
+}
+
+// register lists a copy of r, so records read by Snapshot are never written
+// to. It is called at launch, then again by the goroutine once it has set
+// its GoroutineID.
+func (r *GoroutineRecord) register() {
+	rec := *r
+	registry.Store(r.ID, &rec)
+}
+
+// start sets the GoroutineID of r to the calling goroutine and lists it
+// again. It must be called from the tracked goroutine itself.
+func (r *GoroutineRecord) start() {
+	r.GoroutineID = goid()
+	r.register()
+}
+
+func (r *GoroutineRecord) unregister() { registry.Delete(r.ID) }
+
+// Snapshot returns the live tracked goroutines, oldest first, including
+// those launched but not yet running.
+func Snapshot() []GoroutineRecord {
+	var records []GoroutineRecord
+	registry.Range(func(_, v any) bool {
+		records = append(records, *v.(*GoroutineRecord))
+		return true
+	})
+	slices.SortFunc(records, func(a, b GoroutineRecord) int {
+		return cmp.Or(a.Started.Compare(b.Started), cmp.Compare(a.ID, b.ID))
+	})
+	return records
+}
+
+// WriteDump writes the live tracked goroutines with their launch stacks to w
+// in a human readable format.
+func WriteDump(w io.Writer) error {
+	return writeDump(w, Snapshot(), time.Now())
+}
+
+func writeDump(w io.Writer, records []GoroutineRecord, now time.Time) error {
+	buf := fmt.Appendf(nil, "%d goroutines launched by routine\n", len(records))
+	for _, r := range records {
+		name := r.Name
+		if name == "" {
+			name = "unnamed"
+		}
+		buf = fmt.Appendf(buf, "\ngoroutine %d [%s, running %s]:\nlaunched from:\n%s",
+			r.GoroutineID, name, now.Sub(r.Started).Round(time.Millisecond), formatFrames(r.Stack))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+type jsonRecord struct {
+	ID          uint64      `json:"id"`
+	GoroutineID uint64      `json:"goroutine_id"`
+	Name        string      `json:"name"`
+	Started     time.Time   `json:"started"`
+	RunningNS   int64       `json:"running_ns"`
+	Stack       []jsonFrame `json:"stack"`
+}
+
+type jsonFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// DumpHandler returns an http.Handler serving the tracked goroutines, like
+// the pprof goroutine page but scoped to launch sites of Go. It renders
+// WriteDump's text format, or JSON with the query parameter format=json.
+func DumpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		records, now := Snapshot(), time.Now()
+		if r.URL.Query().Get("format") != "json" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			writeDump(w, records, now)
+			return
+		}
+
+		out := make([]jsonRecord, 0, len(records))
+		for _, rec := range records {
+			jr := jsonRecord{
+				ID:          rec.ID,
+				GoroutineID: rec.GoroutineID,
+				Name:        rec.Name,
+				Started:     rec.Started,
+				RunningNS:   int64(now.Sub(rec.Started)),
+			}
+			for _, f := range rec.Frames() {
+				jr.Stack = append(jr.Stack, jsonFrame{Function: f.Function, File: f.File, Line: f.Line})
+			}
+			out = append(out, jr)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+// goid returns the runtime id of the calling goroutine, parsed from the
+// "goroutine N [" header of runtime.Stack.
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
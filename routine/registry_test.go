@@ -0,0 +1,129 @@
+package routine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRegistry(t *testing.T) {
+	suite.Run(t, new(TestSuiteRegistry))
+}
+
+type TestSuiteRegistry struct {
+	suite.Suite
+}
+
+func (s *TestSuiteRegistry) SetupTest()    { EnableTracking(true) }
+func (s *TestSuiteRegistry) TearDownTest() { EnableTracking(false) }
+
+func (s *TestSuiteRegistry) TestSnapshot() {
+	release := s.launchTracked("worker")
+
+	records := s.findRecords("worker")
+	s.Require().Len(records, 1)
+	rec := records[0]
+	s.NotZero(rec.GoroutineID)
+	s.Require().NotEmpty(rec.Frames())
+	s.True(strings.HasSuffix(rec.Frames()[0].Function, ".launchTracked"), rec.Frames()[0].Function)
+
+	close(release)
+	s.Eventually(func() bool { return len(s.findRecords("worker")) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *TestSuiteRegistry) TestListedAtLaunch() {
+	release := make(chan struct{})
+	defer close(release)
+	for range 100 {
+		Go(func() { <-release }, WithName("launched"))
+	}
+	s.Len(s.findRecords("launched"), 100, "goroutines are listed before they are scheduled")
+	s.Eventually(func() bool {
+		for _, r := range s.findRecords("launched") {
+			if r.GoroutineID == 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+}
+
+func (s *TestSuiteRegistry) TestUntrackedWhenDisabled() {
+	EnableTracking(false)
+	release := s.launchTracked("disabled")
+	defer close(release)
+	s.Empty(s.findRecords("disabled"))
+}
+
+func (s *TestSuiteRegistry) TestWriteDump() {
+	release := s.launchTracked("dumped")
+	defer close(release)
+	s.Require().Len(s.findRecords("dumped"), 1)
+
+	var buf bytes.Buffer
+	s.Require().NoError(WriteDump(&buf))
+	s.T().Logf("dump:\n%s", buf.String())
+	s.Contains(buf.String(), "goroutines launched by routine")
+	s.Contains(buf.String(), "[dumped, running ")
+	s.Contains(buf.String(), "registry_test.go")
+}
+
+func (s *TestSuiteRegistry) TestDumpHandler() {
+	release := s.launchTracked("served")
+	defer close(release)
+	s.Require().Len(s.findRecords("served"), 1)
+
+	rec := httptest.NewRecorder()
+	DumpHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?format=json", nil))
+	s.Equal("application/json", rec.Header().Get("Content-Type"))
+
+	var got []jsonRecord
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &got))
+	var found bool
+	for _, r := range got {
+		if r.Name == "served" {
+			found = true
+			s.NotEmpty(r.Stack)
+		}
+	}
+	s.True(found)
+
+	rec = httptest.NewRecorder()
+	DumpHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	s.Contains(rec.Body.String(), "[served, running ")
+}
+
+func (s *TestSuiteRegistry) TestGoid() {
+	ids := make(chan uint64, 1)
+	go func() { ids <- goid() }()
+	s.NotZero(goid())
+	s.NotEqual(goid(), <-ids)
+}
+
+// launchTracked starts a goroutine that blocks until release is closed, and
+// waits until it has registered itself.
+func (s *TestSuiteRegistry) launchTracked(name string) (release chan struct{}) {
+	release = make(chan struct{})
+	started := make(chan struct{})
+	Go(func() {
+		close(started)
+		<-release
+	}, WithName(name))
+	<-started
+	return release
+}
+
+func (s *TestSuiteRegistry) findRecords(name string) []GoroutineRecord {
+	var out []GoroutineRecord
+	for _, r := range Snapshot() {
+		if r.Name == name {
+			out = append(out, r)
+		}
+	}
+	return out
+}
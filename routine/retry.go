@@ -0,0 +1,152 @@
+package routine
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes the delay before the next attempt of Retry.
+type Backoff interface {
+	// Next returns the delay after the given failed attempt, counting from 1.
+	// prev is the delay returned for the previous attempt, zero at first.
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc adapts a function to the Backoff interface.
+type BackoffFunc func(attempt int, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Next(attempt int, prev time.Duration) time.Duration { return f(attempt, prev) }
+
+// Jitter randomizes exponential backoff delays, following
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+type Jitter int
+
+const (
+	// NoJitter uses the exact exponential delay.
+	NoJitter Jitter = iota
+	// FullJitter picks a delay in [0, d).
+	FullJitter
+	// EqualJitter picks a delay in [d/2, d).
+	EqualJitter
+	// DecorrelatedJitter picks a delay in [base, prev*3), growing from the
+	// previous delay rather than the attempt number.
+	DecorrelatedJitter
+)
+
+// ConstantBackoff waits d between attempts.
+func ConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(int, time.Duration) time.Duration { return d })
+}
+
+// ExponentialBackoff waits base*2^(attempt-1), capped at maxDelay and
+// randomized by jitter.
+func ExponentialBackoff(base, maxDelay time.Duration, jitter Jitter) Backoff {
+	return BackoffFunc(func(attempt int, prev time.Duration) time.Duration {
+		if jitter == DecorrelatedJitter {
+			return min(maxDelay, base+randDuration(max(prev*3, base)-base))
+		}
+		d := maxDelay
+		if shift := attempt - 1; shift < 63 && base <= maxDelay>>shift {
+			d = base << shift
+		}
+		switch jitter {
+		case FullJitter:
+			return randDuration(d)
+		case EqualJitter:
+			return d/2 + randDuration(d-d/2)
+		default:
+			return d
+		}
+	})
+}
+
+// FibonacciBackoff waits base*fib(attempt), i.e. base, base, 2*base, 3*base,
+// 5*base..., capped at maxDelay.
+func FibonacciBackoff(base, maxDelay time.Duration) Backoff {
+	return BackoffFunc(func(attempt int, _ time.Duration) time.Duration {
+		a, b := time.Duration(0), base
+		for range attempt - 1 {
+			a, b = b, a+b
+			if b >= maxDelay || b < 0 {
+				return maxDelay
+			}
+		}
+		return min(b, maxDelay)
+	})
+}
+
+// randDuration returns a random duration in [0, d), or 0 if d <= 0.
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)))
+}
+
+// RetryPolicy configures Retry. The zero value retries every error
+// immediately and forever, until ctx is done.
+type RetryPolicy struct {
+	// Backoff computes the delay between attempts. Nil means no delay.
+	Backoff Backoff
+	// MaxAttempts limits the number of attempts. Zero means unlimited.
+	MaxAttempts int
+	// MaxElapsed stops retrying when the next attempt would start later than
+	// this after the first one. Zero means unlimited.
+	MaxElapsed time.Duration
+	// Retryable reports whether an error, including a *PanicError, is worth
+	// another attempt. Nil retries every error.
+	Retryable func(error) bool
+	// OnRetry is called before waiting for the next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+	// Options are passed to Recover for every attempt. WithClock sets the
+	// clock of delays and elapsed time.
+	Options []Option
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, or the
+// policy gives up, and returns the last error. Every attempt is wrapped with
+// Recover, so a panic fails the attempt with a *PanicError instead of
+// crashing. If ctx is done while waiting, the returned error wraps both
+// ctx.Err() and the last error.
+func Retry(ctx context.Context, fn func(context.Context) error, policy RetryPolicy) error {
+	// WithCallerSkip(1) skips Retry itself; policy options follow.
+	opts := append([]Option{WithCallerSkip(1)}, policy.Options...)
+	cfg := newConfig(opts)
+	clock := cfg.timer()
+	attempt := Recover(func() error { return fn(ctx) }, opts...)
+
+	start := clock.Now()
+	var delay time.Duration
+	for n := 1; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return err
+		}
+		if policy.MaxAttempts > 0 && n >= policy.MaxAttempts {
+			return err
+		}
+
+		if policy.Backoff != nil {
+			delay = policy.Backoff.Next(n, delay)
+		}
+		if policy.MaxElapsed > 0 && clock.Now().Add(delay).Sub(start) > policy.MaxElapsed {
+			return err
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(n, err, delay)
+		}
+		if delay > 0 {
+			if ctxErr := sleep(ctx, clock, delay); ctxErr != nil {
+				return fmt.Errorf("routine: retry aborted: %w; last error: %w", ctxErr, err)
+			}
+		}
+	}
+}
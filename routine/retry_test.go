@@ -0,0 +1,166 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRetry(t *testing.T) {
+	suite.Run(t, new(TestSuiteRetry))
+}
+
+type TestSuiteRetry struct {
+	suite.Suite
+}
+
+var errTransient = errors.New("transient")
+
+func (s *TestSuiteRetry) TestSucceedsAfterRetries() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var delays []time.Duration
+	calls := 0
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(context.Background(), func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		}, RetryPolicy{
+			Backoff: ExponentialBackoff(time.Second, time.Minute, NoJitter),
+			OnRetry: func(_ int, _ error, d time.Duration) { delays = append(delays, d) },
+			Options: []Option{WithClock(clock)},
+		})
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
+
+	s.NoError(<-done)
+	s.Equal(3, calls)
+	s.Equal([]time.Duration{time.Second, 2 * time.Second}, delays)
+}
+
+func (s *TestSuiteRetry) TestMaxAttempts() {
+	calls := 0
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		return errTransient
+	}, RetryPolicy{MaxAttempts: 3})
+
+	s.ErrorIs(err, errTransient)
+	s.Equal(3, calls)
+}
+
+func (s *TestSuiteRetry) TestMaxElapsed() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	calls := 0
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(context.Background(), func(context.Context) error {
+			calls++
+			return errTransient
+		}, RetryPolicy{
+			Backoff:    ConstantBackoff(time.Second),
+			MaxElapsed: 1500 * time.Millisecond,
+			Options:    []Option{WithClock(clock)},
+		})
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	s.ErrorIs(<-done, errTransient)
+	s.Equal(2, calls)
+}
+
+func (s *TestSuiteRetry) TestNotRetryable() {
+	calls := 0
+	errFatal := errors.New("fatal")
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		return errFatal
+	}, RetryPolicy{Retryable: func(err error) bool { return !errors.Is(err, errFatal) }})
+
+	s.ErrorIs(err, errFatal)
+	s.Equal(1, calls)
+}
+
+func (s *TestSuiteRetry) TestRecoversPanicPerAttempt() {
+	calls := 0
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		if calls == 1 {
+			panic("first attempt")
+		}
+		return nil
+	}, RetryPolicy{})
+
+	s.NoError(err)
+	s.Equal(2, calls)
+
+	err = Retry(context.Background(), func(context.Context) error { panic("always") }, RetryPolicy{MaxAttempts: 2})
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Contains(err.Error(), "retry_test.go")
+}
+
+func (s *TestSuiteRetry) TestContextCanceledWhileWaiting() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(ctx, func(context.Context) error { return errTransient },
+			RetryPolicy{Backoff: ConstantBackoff(time.Hour), Options: []Option{WithClock(clock)}})
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+	err := <-done
+	s.ErrorIs(err, context.Canceled)
+	s.ErrorIs(err, errTransient)
+}
+
+func (s *TestSuiteRetry) TestExponentialBackoff() {
+	b := ExponentialBackoff(time.Second, 10*time.Second, NoJitter)
+	s.Equal(time.Second, b.Next(1, 0))
+	s.Equal(2*time.Second, b.Next(2, 0))
+	s.Equal(8*time.Second, b.Next(4, 0))
+	s.Equal(10*time.Second, b.Next(5, 0))
+	s.Equal(10*time.Second, b.Next(200, 0))
+
+	full := ExponentialBackoff(time.Second, 10*time.Second, FullJitter)
+	equal := ExponentialBackoff(time.Second, 10*time.Second, EqualJitter)
+	decorrelated := ExponentialBackoff(time.Second, 10*time.Second, DecorrelatedJitter)
+	for range 100 {
+		s.Less(full.Next(3, 0), 4*time.Second)
+		d := equal.Next(3, 0)
+		s.GreaterOrEqual(d, 2*time.Second)
+		s.Less(d, 4*time.Second)
+		d = decorrelated.Next(3, 2*time.Second)
+		s.GreaterOrEqual(d, time.Second)
+		s.Less(d, 6*time.Second)
+		s.LessOrEqual(decorrelated.Next(3, time.Hour), 10*time.Second)
+	}
+}
+
+func (s *TestSuiteRetry) TestFibonacciBackoff() {
+	b := FibonacciBackoff(time.Second, 6*time.Second)
+	var got []time.Duration
+	for n := 1; n <= 6; n++ {
+		got = append(got, b.Next(n, 0))
+	}
+	s.Equal([]time.Duration{
+		time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 6 * time.Second,
+	}, got)
+}
@@ -1,53 +1,77 @@
 package routine
 
-import (
-	"fmt"
-	"os"
-	"runtime"
-)
+import "time"
 
 // Recover returns a function that calls fn with panic recovery.
-// If fn panics, the returned function returns a formatted error
+// If fn panics, the returned function returns a *PanicError
 // containing both the panic stack and the caller stack.
 // Must be called before launching the goroutine to capture the caller stack.
 func Recover(fn func() error, options ...Option) func() error {
-	var cfg config
-	for _, opt := range options {
-		opt(&cfg)
-	}
+	cfg := newConfig(options)
 
 	var callerFrames []uintptr
 	if !cfg.noCallerStack {
-		var callerPCs [32]uintptr
-		// skip: runtime.Callers + Wrap + user-requested extra
-		n := runtime.Callers(2+cfg.callerSkip, callerPCs[:])
-		callerFrames = callerPCs[:n]
+		// skip: Recover + user-requested extra
+		callerFrames = callers(1+cfg.callerSkip, cfg.stack.maxDepth)
+	}
+	return recoverWith(&cfg, callerFrames, fn)
+}
+
+// launchStack captures the stack above a launching function like Go, the
+// frames Recover would capture there, when anything needs it.
+func launchStack(cfg *config) []uintptr {
+	if cfg.noCallerStack && !tracking.Load() && cfg.leakGrace <= 0 && cfg.stallBudget <= 0 {
+		return nil
+	}
+	// skip: launchStack, then the same frames above the launcher as Recover
+	return callers(1+cfg.callerSkip, cfg.stack.maxDepth)
+}
+
+// callerStack is the caller stack Recover keeps out of the launch stack.
+func callerStack(cfg *config, launch []uintptr) []uintptr {
+	if cfg.noCallerStack {
+		return nil
 	}
+	return launch
+}
 
+// recoverWith is Recover with an already captured caller stack, for helpers
+// wrapping many functions launched from the same call site.
+func recoverWith(cfg *config, callerFrames []uintptr, fn func() error) func() error {
+	stack := &cfg.stack
 	capturePanicStack := !cfg.noPanicStack
+	name := cfg.name
+	observers := observersFor(cfg)
 
 	return func() (err error) {
+		var start time.Time
+		if len(observers) > 0 {
+			start = time.Now()
+			for _, o := range observers {
+				o.OnStart(name)
+			}
+		}
 		defer func() {
 			if r := recover(); r != nil {
-				var panicErr error
-				if e, ok := r.(error); ok {
-					panicErr = e
-				} else {
-					panicErr = fmt.Errorf("%+v", r)
-				}
-
-				msg := fmt.Appendf(nil, "panic: %s", panicErr)
+				panicErr := newPanicError(r)
+				panicErr.Name = name
 				if capturePanicStack {
-					// skip: captureStack + this defer + runtime.gopanic
-					msg = fmt.Appendf(msg, "\n\n%s", captureStack(2))
+					// skip: callers + this defer + runtime.gopanic
+					panicErr.PanicStack = callers(2, stack.maxDepth)
 				}
-				if len(callerFrames) > 0 {
-					msg = fmt.Appendf(msg, "\ncalled from:\n\n%s", formatFrames(callerFrames))
+				panicErr.CallerStack = callerFrames
+				panicErr.stack = stack
+				panicErr.Original = err
+				err = panicErr
+				for _, o := range observers {
+					o.OnPanic(name, panicErr)
 				}
-				if err != nil {
-					msg = fmt.Appendf(msg, "\noriginal error: %+v", err)
+			}
+			if len(observers) > 0 {
+				d := time.Since(start)
+				for _, o := range observers {
+					o.OnFinish(name, d, err)
 				}
-				err = fmt.Errorf("%s", msg)
 			}
 		}()
 		return fn()
@@ -57,23 +81,31 @@ func Recover(fn func() error, options ...Option) func() error {
 // Go launches fn in a new goroutine with panic recovery.
 // Panics are recovered and the resulting error is passed to the error handler.
 // If no WithErrorHandler option is provided, errors are written to stderr.
+// While tracking is enabled, the goroutine is listed by Snapshot until fn returns.
+// WithWatchdog reports the goroutine when it runs longer than a budget.
 func Go(fn func(), options ...Option) {
-	var cfg config
-	for _, opt := range options {
-		opt(&cfg)
-	}
-
-	errHandler := cfg.errorHandler
-	if errHandler == nil {
-		errHandler = func(err error) { fmt.Fprintln(os.Stderr, err.Error()) }
-	}
-
 	// WithCallerSkip(1) is the baseline to skip Go itself; user options follow
 	// and can override it (e.g. WithCallerSkip(2) to also skip a wrapper).
 	opts := append([]Option{WithCallerSkip(1)}, options...)
-	wrapped := Recover(func() error { fn(); return nil }, opts...)
+	cfg := newConfig(opts)
+	errHandler := cfg.handler()
+	launch := launchStack(&cfg)
+	wrapped := recoverWith(&cfg, callerStack(&cfg, launch), func() error { fn(); return nil })
+
+	var record *GoroutineRecord
+	if tracking.Load() {
+		record = newRecord(cfg.name, launch)
+		record.register()
+	}
 	go func() {
-		if err := wrapped(); err != nil {
+		if record != nil {
+			record.start()
+			defer record.unregister()
+		}
+		stopWatchdog := startWatchdog(&cfg, launch, errHandler)
+		err := wrapped()
+		stopWatchdog()
+		if err != nil {
 			errHandler(err)
 		}
 	}()
@@ -1,6 +1,7 @@
 package routine
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"testing"
@@ -34,3 +35,90 @@ func BenchmarkRuntimeCallers(b *testing.B) {
 		runtime.Callers(2, pcs[:])
 	}
 }
+
+// BenchmarkPool vs BenchmarkGo: reuse workers instead of spawning per task
+func BenchmarkPool(b *testing.B) {
+	p := NewPool(runtime.GOMAXPROCS(0), WithQueueSize(1024))
+	defer p.Shutdown(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	b.ResetTimer()
+	for range b.N {
+		p.Submit(func() { wg.Done() })
+	}
+	wg.Wait()
+}
+
+func BenchmarkPoolParallel(b *testing.B) {
+	p := NewPool(runtime.GOMAXPROCS(0), WithQueueSize(1024))
+	defer p.Shutdown(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Submit(func() { wg.Done() })
+		}
+	})
+	wg.Wait()
+}
+
+func BenchmarkGoParallel(b *testing.B) {
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Go(func() { wg.Done() })
+		}
+	})
+	wg.Wait()
+}
+
+var benchItems = make([]int, 1024)
+
+func benchWork(v int) int {
+	for i := range 1000 {
+		v += i * i
+	}
+	return v
+}
+
+// BenchmarkNaiveLoop vs BenchmarkParallelMap: sequential baseline for the
+// same CPU-bound work per item
+func BenchmarkNaiveLoop(b *testing.B) {
+	for range b.N {
+		results := make([]int, len(benchItems))
+		for i, v := range benchItems {
+			results[i] = benchWork(v)
+		}
+	}
+}
+
+func BenchmarkParallelMap(b *testing.B) {
+	ctx := context.Background()
+	for range b.N {
+		ParallelMap(ctx, benchItems, runtime.GOMAXPROCS(0), func(_ context.Context, v int) (int, error) {
+			return benchWork(v), nil
+		})
+	}
+}
+
+// Hand-written semaphore with Go, as ParallelMap replaces
+func BenchmarkGoSemaphore(b *testing.B) {
+	for range b.N {
+		results := make([]int, len(benchItems))
+		sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+		var wg sync.WaitGroup
+		wg.Add(len(benchItems))
+		for i, v := range benchItems {
+			sem <- struct{}{}
+			Go(func() {
+				defer wg.Done()
+				results[i] = benchWork(v)
+				<-sem
+			})
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,258 @@
+// Package routinetest provides test helpers for code launching goroutines,
+// with or without package routine.
+package routinetest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeluyang/gopkg/routine"
+)
+
+// Option configures leak checks.
+type Option func(*options)
+
+type options struct {
+	ignoreTop []string
+	ignoreAny []string
+	ignoreIDs []uint64
+	settle    time.Duration
+}
+
+// IgnoreTopFunction ignores goroutines whose innermost frame, after runtime
+// internals such as runtime.gopark, is in fn.
+func IgnoreTopFunction(fn string) Option {
+	return func(o *options) {
+		o.ignoreTop = append(o.ignoreTop, fn)
+	}
+}
+
+// IgnoreAnyFunction ignores goroutines with fn anywhere in their stack.
+func IgnoreAnyFunction(fn string) Option {
+	return func(o *options) {
+		o.ignoreAny = append(o.ignoreAny, fn)
+	}
+}
+
+// IgnoreCurrent ignores the goroutines running when IgnoreCurrent is called,
+// so that only goroutines started afterwards are reported, e.g.
+//
+//	defer routinetest.VerifyNone(t, routinetest.IgnoreCurrent())
+func IgnoreCurrent() Option {
+	var ids []uint64
+	for _, g := range goroutines() {
+		ids = append(ids, g.ID)
+	}
+	return func(o *options) {
+		o.ignoreIDs = append(o.ignoreIDs, ids...)
+	}
+}
+
+// SettleTimeout sets how long leaked goroutines are given to exit before
+// they are reported. Defaults to one second.
+func SettleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.settle = d
+	}
+}
+
+// benign are functions of goroutines the Go runtime and package testing
+// keep around.
+var benign = []string{
+	"testing.RunTests",
+	"testing.(*T).Run",
+	"testing.(*M).Run",
+	"testing.(*M).startAlarm",
+	"testing.runFuzzing",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"runtime.ReadTrace",
+}
+
+// Check reports goroutines that are still running after the settle timeout,
+// except the calling one and the ignored ones. The error lists each leaked
+// goroutine with its stack and, if it was launched by routine.Go while
+// routine tracking was enabled, the site it was launched from.
+func Check(opts ...Option) error {
+	o := options{settle: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	deadline := time.Now().Add(o.settle)
+	wait := time.Millisecond
+	for {
+		leaked := o.leaked()
+		if len(leaked) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return leakError(leaked)
+		}
+		time.Sleep(wait)
+		wait = min(2*wait, 100*time.Millisecond)
+	}
+}
+
+// VerifyNone fails t if Check reports leaked goroutines.
+func VerifyNone(t testing.TB, opts ...Option) {
+	t.Helper()
+	if err := Check(opts...); err != nil {
+		t.Error(err)
+	}
+}
+
+// VerifyTestMain runs the tests of m with routine tracking enabled, then
+// fails the test binary if any goroutine leaked. Use it from TestMain:
+//
+//	func TestMain(m *testing.M) { routinetest.VerifyTestMain(m) }
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	routine.EnableTracking(true)
+	code := m.Run()
+	if code == 0 {
+		if err := Check(opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "routinetest: %v\n", err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func (o *options) leaked() []goroutine {
+	self := currentID()
+	var out []goroutine
+	for _, g := range goroutines() {
+		if g.ID == self || slices.Contains(o.ignoreIDs, g.ID) || o.ignored(g) {
+			continue
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+func (o *options) ignored(g goroutine) bool {
+	for _, fn := range benign {
+		if slices.Contains(g.Functions, fn) {
+			return true
+		}
+	}
+	for _, fn := range o.ignoreAny {
+		if slices.Contains(g.Functions, fn) {
+			return true
+		}
+	}
+	return slices.Contains(o.ignoreTop, g.top())
+}
+
+func leakError(leaked []goroutine) error {
+	launches := make(map[uint64]routine.GoroutineRecord)
+	for _, r := range routine.Snapshot() {
+		launches[r.GoroutineID] = r
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "found %d leaked goroutines:", len(leaked))
+	for _, g := range leaked {
+		fmt.Fprintf(&buf, "\n\n%s", g.Trace)
+		if r, ok := launches[g.ID]; ok {
+			name := r.Name
+			if name == "" {
+				name = "unnamed"
+			}
+			fmt.Fprintf(&buf, "\nlaunched by routine [%s] from:\n", name)
+			for _, f := range r.Frames() {
+				fmt.Fprintf(&buf, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+			}
+		}
+	}
+	return fmt.Errorf("%s", strings.TrimRight(buf.String(), "\n"))
+}
+
+// goroutine is one entry of a runtime.Stack dump of all goroutines.
+type goroutine struct {
+	ID        uint64
+	State     string
+	Functions []string // innermost first, without arguments
+	Trace     string
+}
+
+// top returns the innermost function outside package runtime.
+func (g goroutine) top() string {
+	for _, fn := range g.Functions {
+		if !strings.HasPrefix(fn, "runtime.") {
+			return fn
+		}
+	}
+	return ""
+}
+
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var out []goroutine
+	for block := range strings.SplitSeq(string(buf), "\n\n") {
+		if g, ok := parseGoroutine(block); ok {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// parseGoroutine parses a block such as
+//
+//	goroutine 7 [chan receive]:
+//	main.worker(0xc000010000)
+//		/path/main.go:12 +0x25
+//	created by main.main in goroutine 1
+//		/path/main.go:8 +0x1d
+func parseGoroutine(block string) (goroutine, bool) {
+	block = strings.TrimSpace(block)
+	header, body, _ := strings.Cut(block, "\n")
+	rest, ok := strings.CutPrefix(header, "goroutine ")
+	if !ok {
+		return goroutine{}, false
+	}
+	idStr, state, ok := strings.Cut(rest, " [")
+	if !ok {
+		return goroutine{}, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return goroutine{}, false
+	}
+
+	g := goroutine{ID: id, State: strings.TrimSuffix(state, "]:"), Trace: block}
+	for line := range strings.SplitSeq(body, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "created by ") {
+			continue
+		}
+		fn := line
+		if i := strings.LastIndexByte(fn, '('); i > 0 {
+			fn = fn[:i]
+		}
+		g.Functions = append(g.Functions, fn)
+	}
+	return g, true
+}
+
+func currentID() uint64 {
+	var buf [64]byte
+	g, _ := parseGoroutine(string(buf[:runtime.Stack(buf[:], false)]))
+	return g.ID
+}
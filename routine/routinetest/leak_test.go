@@ -0,0 +1,123 @@
+package routinetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/yeluyang/gopkg/routine"
+)
+
+func TestMain(m *testing.M) {
+	VerifyTestMain(m)
+}
+
+func TestLeak(t *testing.T) {
+	suite.Run(t, new(TestSuiteLeak))
+}
+
+type TestSuiteLeak struct {
+	suite.Suite
+}
+
+func (s *TestSuiteLeak) TestNoLeak() {
+	defer VerifyNone(s.T(), IgnoreCurrent())
+
+	done := make(chan struct{})
+	routine.Go(func() { close(done) })
+	<-done
+}
+
+func (s *TestSuiteLeak) TestSettle() {
+	ignore := IgnoreCurrent()
+	routine.Go(func() { time.Sleep(50 * time.Millisecond) })
+	s.NoError(Check(ignore, SettleTimeout(time.Second)))
+}
+
+func (s *TestSuiteLeak) TestReportsLaunchSite() {
+	ignore := IgnoreCurrent()
+	release := make(chan struct{})
+	defer close(release)
+	leakyCaller(release)
+
+	err := Check(ignore, SettleTimeout(50*time.Millisecond))
+	s.Require().Error(err)
+	s.T().Logf("leak error:\n%s", err.Error())
+	s.Contains(err.Error(), "found 1 leaked goroutines")
+	s.Contains(err.Error(), "launched by routine [leaky] from:")
+	s.Contains(err.Error(), "routinetest.leakyCaller")
+}
+
+func (s *TestSuiteLeak) TestUnstoppedTicker() {
+	ignore := IgnoreCurrent()
+	stop := make(chan struct{})
+	defer close(stop)
+	tickerLoop(stop)
+
+	err := Check(ignore, SettleTimeout(50*time.Millisecond))
+	s.Require().Error(err, "a ticker loop never stopped leaks, as a rate.Limiter never closed")
+	s.Contains(err.Error(), "launched by routine [ticker] from:")
+	s.Contains(err.Error(), "routinetest.tickerLoop")
+}
+
+func (s *TestSuiteLeak) TestPlainGoroutine() {
+	ignore := IgnoreCurrent()
+	release := make(chan struct{})
+	defer close(release)
+	go func() { <-release }()
+
+	err := Check(ignore, SettleTimeout(50*time.Millisecond))
+	s.Require().Error(err)
+	s.NotContains(err.Error(), "launched by routine")
+}
+
+func (s *TestSuiteLeak) TestIgnoreFunctions() {
+	ignore := IgnoreCurrent()
+	release := make(chan struct{})
+	defer close(release)
+	leakyCaller(release)
+
+	s.NoError(Check(ignore, SettleTimeout(50*time.Millisecond),
+		IgnoreAnyFunction("github.com/yeluyang/gopkg/routine.Go.func1")))
+	s.NoError(Check(ignore, SettleTimeout(50*time.Millisecond),
+		IgnoreTopFunction("github.com/yeluyang/gopkg/routine/routinetest.leakyCaller.func1")))
+}
+
+func (s *TestSuiteLeak) TestParseGoroutine() {
+	g, ok := parseGoroutine("goroutine 7 [chan receive]:\n" +
+		"runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)\n" +
+		"\t/go/src/runtime/proc.go:435 +0xce\n" +
+		"main.worker(0xc000010000)\n" +
+		"\t/path/main.go:12 +0x25\n" +
+		"created by main.main in goroutine 1\n" +
+		"\t/path/main.go:8 +0x1d")
+	s.Require().True(ok)
+	s.Equal(uint64(7), g.ID)
+	s.Equal("chan receive", g.State)
+	s.Equal([]string{"runtime.gopark", "main.worker"}, g.Functions)
+	s.Equal("main.worker", g.top())
+
+	_, ok = parseGoroutine("not a goroutine")
+	s.False(ok)
+}
+
+func leakyCaller(release chan struct{}) {
+	routine.Go(func() { <-release }, routine.WithName("leaky"))
+}
+
+// tickerLoop polls like the refresh loop of rate.Limiter until stop is
+// closed.
+func tickerLoop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Millisecond)
+	routine.Go(func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}, routine.WithName("ticker"))
+}
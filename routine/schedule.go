@@ -0,0 +1,122 @@
+package routine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what a scheduled task does when an activation is due
+// while the previous run is still going.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip drops the activation.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs once more right after the current run for every
+	// activation that was due meanwhile.
+	OverlapQueue
+)
+
+// Every runs fn every interval until ctx is done. See RunSchedule for the
+// available options and the returned channel. Like time.NewTicker, it panics
+// if interval is not positive.
+func Every(ctx context.Context, interval time.Duration, fn func(context.Context) error, options ...Option) <-chan struct{} {
+	if interval <= 0 {
+		panic("routine: non-positive interval for Every")
+	}
+	cfg := newConfig(options)
+	return runSchedule(ctx, IntervalSchedule(interval), fn, &cfg, cfg.callerStack())
+}
+
+// Cron runs fn on the cron expression spec until ctx is done. See ParseCron
+// for the syntax and RunSchedule for the options and the returned channel.
+func Cron(ctx context.Context, spec string, fn func(context.Context) error, options ...Option) (<-chan struct{}, error) {
+	sched, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(options)
+	return runSchedule(ctx, sched, fn, &cfg, cfg.callerStack()), nil
+}
+
+// RunSchedule runs fn at every activation of sched until ctx is done, in a
+// background goroutine. Every run is wrapped with Recover; returned errors
+// and recovered panics are passed to the error handler and never stop the
+// schedule.
+//
+// WithImmediate runs fn once right away, WithJitter delays each activation
+// by a random duration, WithOverlap decides what happens to activations due
+// while a run is still going, and WithClock replaces the clock for tests.
+//
+// The returned channel is closed once ctx is done and the last run returned.
+func RunSchedule(ctx context.Context, sched Schedule, fn func(context.Context) error, options ...Option) <-chan struct{} {
+	cfg := newConfig(options)
+	return runSchedule(ctx, sched, fn, &cfg, cfg.callerStack())
+}
+
+func runSchedule(ctx context.Context, sched Schedule, fn func(context.Context) error, cfg *config, callerFrames []uintptr) <-chan struct{} {
+	clock := cfg.timer()
+	handler := cfg.handler()
+	run := recoverWith(cfg, callerFrames, func() error { return fn(ctx) })
+
+	var (
+		mu      sync.Mutex
+		running bool
+		pending int
+		runs    sync.WaitGroup
+	)
+	trigger := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if running {
+			if cfg.overlap == OverlapQueue {
+				pending++
+			}
+			return
+		}
+		running = true
+		runs.Add(1)
+		go func() {
+			defer runs.Done()
+			for {
+				if err := run(); err != nil {
+					handler(err)
+				}
+				mu.Lock()
+				if pending == 0 || ctx.Err() != nil {
+					running, pending = false, 0
+					mu.Unlock()
+					return
+				}
+				pending--
+				mu.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer runs.Wait()
+
+		if cfg.immediate {
+			trigger()
+		}
+		now := clock.Now()
+		for next := sched.Next(now); !next.IsZero(); {
+			if sleep(ctx, clock, next.Sub(now)+randDuration(cfg.jitter)) != nil {
+				return
+			}
+			trigger()
+			// keep the cadence of the schedule, but skip activations missed
+			// while sleeping longer than planned
+			now = clock.Now()
+			if next = sched.Next(next); !next.IsZero() && next.Before(now) {
+				next = sched.Next(now)
+			}
+		}
+		<-ctx.Done()
+	}()
+	return done
+}
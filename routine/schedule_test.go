@@ -0,0 +1,180 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSchedule(t *testing.T) {
+	suite.Run(t, new(TestSuiteSchedule))
+}
+
+type TestSuiteSchedule struct {
+	suite.Suite
+}
+
+func (s *TestSuiteSchedule) TestEvery() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan struct{})
+
+	done := Every(ctx, time.Second, func(context.Context) error {
+		ticks <- struct{}{}
+		return nil
+	}, WithClock(clock))
+
+	for range 3 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		s.receive(ticks)
+	}
+
+	cancel()
+	s.receiveDone(done)
+}
+
+func (s *TestSuiteSchedule) TestEveryRejectsNonPositiveInterval() {
+	noop := func(context.Context) error { return nil }
+	s.Panics(func() { Every(context.Background(), 0, noop) })
+	s.Panics(func() { Every(context.Background(), -time.Second, noop) })
+}
+
+func (s *TestSuiteSchedule) TestImmediate() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan struct{}, 1)
+
+	done := Every(ctx, time.Hour, func(context.Context) error {
+		ticks <- struct{}{}
+		return nil
+	}, WithClock(clock), WithImmediate(true))
+
+	s.receive(ticks)
+	cancel()
+	s.receiveDone(done)
+}
+
+func (s *TestSuiteSchedule) TestOverlapSkipAndQueue() {
+	for _, tc := range []struct {
+		policy OverlapPolicy
+		runs   int32
+	}{
+		{OverlapSkip, 1},
+		{OverlapQueue, 3},
+	} {
+		clock := NewFakeClock(time.Unix(0, 0))
+		ctx, cancel := context.WithCancel(context.Background())
+		release := make(chan struct{})
+		var runs atomic.Int32
+
+		done := Every(ctx, time.Second, func(context.Context) error {
+			if runs.Add(1) == 1 {
+				<-release
+			}
+			return nil
+		}, WithClock(clock), WithOverlap(tc.policy))
+
+		// three activations while the first run is blocked
+		for range 3 {
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+		}
+		clock.BlockUntil(1)
+		close(release)
+		s.Eventually(func() bool { return runs.Load() == tc.runs }, time.Second, time.Millisecond)
+		cancel()
+		s.receiveDone(done)
+		s.Equal(tc.runs, runs.Load())
+	}
+}
+
+func (s *TestSuiteSchedule) TestErrorsAndPanicsReported() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 2)
+	errTick := errors.New("tick failed")
+	var runs atomic.Int32
+
+	done := Every(ctx, time.Second, func(context.Context) error {
+		if runs.Add(1) == 1 {
+			return errTick
+		}
+		panic("tick panic")
+	}, WithClock(clock), WithErrorHandler(func(err error) { errCh <- err }))
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	s.ErrorIs(<-errCh, errTick)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	var pe *PanicError
+	s.ErrorAs(<-errCh, &pe)
+	s.Contains(pe.Error(), "schedule_test.go")
+
+	cancel()
+	s.receiveDone(done)
+}
+
+func (s *TestSuiteSchedule) TestJitter() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan struct{}, 1)
+
+	done := Every(ctx, time.Second, func(context.Context) error {
+		ticks <- struct{}{}
+		return nil
+	}, WithClock(clock), WithJitter(time.Second))
+
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
+	s.receive(ticks)
+	cancel()
+	s.receiveDone(done)
+}
+
+func (s *TestSuiteSchedule) TestCron() {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan time.Time, 1)
+
+	done, err := Cron(ctx, "0 * * * *", func(context.Context) error {
+		ticks <- clock.Now()
+		return nil
+	}, WithClock(clock))
+	s.Require().NoError(err)
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	select {
+	case now := <-ticks:
+		s.Equal(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), now)
+	case <-time.After(time.Second):
+		s.Fail("cron did not run")
+	}
+	cancel()
+	s.receiveDone(done)
+
+	_, err = Cron(ctx, "bad", func(context.Context) error { return nil })
+	s.Error(err)
+}
+
+func (s *TestSuiteSchedule) receive(ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		s.FailNow("task did not run")
+	}
+}
+
+func (s *TestSuiteSchedule) receiveDone(done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.FailNow("schedule did not stop")
+	}
+}
@@ -0,0 +1,133 @@
+package routine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Singleflight suppresses duplicate concurrent calls for the same key, like
+// golang.org/x/sync/singleflight, with panics recovered: a panic in the
+// shared call becomes the same *PanicError for every waiter instead of
+// re-panicking in each of them.
+//
+// The zero value is ready to use.
+type Singleflight[K comparable, V any] struct {
+	// TTL keeps a successful result for this long after the call completes,
+	// so callers arriving shortly after share it instead of calling again.
+	// Errors are never kept. Zero disables it. Set before first use.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	calls map[K]*flight[V]
+}
+
+// SingleflightResult holds the results of Singleflight.DoChan.
+type SingleflightResult[V any] struct {
+	Val    V
+	Err    error
+	Shared bool
+}
+
+type flight[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	dups    atomic.Int32
+	expires time.Time // guarded by Singleflight.mu
+}
+
+func (f *flight[V]) result() SingleflightResult[V] {
+	return SingleflightResult[V]{Val: f.val, Err: f.err, Shared: f.dups.Load() > 0}
+}
+
+// Do calls fn once for all concurrent callers with the same key and returns
+// its results; shared reports whether they were given to more than one
+// caller. fn runs in its own goroutine with the first caller's context
+// stripped of cancellation, so a caller giving up early, which returns
+// ctx.Err(), does not fail the others.
+func (g *Singleflight[K, V]) Do(ctx context.Context, key K, fn func(context.Context) (V, error), options ...Option) (v V, err error, shared bool) {
+	cfg := newConfig(options)
+	f := g.start(ctx, key, fn, &cfg, cfg.callerStack())
+	select {
+	case <-f.done:
+		r := f.result()
+		return r.Val, r.Err, r.Shared
+	case <-ctx.Done():
+		return v, ctx.Err(), false
+	}
+}
+
+// DoChan is like Do but returns a channel that receives the results when
+// they are ready. The channel is never closed.
+func (g *Singleflight[K, V]) DoChan(ctx context.Context, key K, fn func(context.Context) (V, error), options ...Option) <-chan SingleflightResult[V] {
+	cfg := newConfig(options)
+	f := g.start(ctx, key, fn, &cfg, cfg.callerStack())
+	ch := make(chan SingleflightResult[V], 1)
+	go func() {
+		<-f.done
+		ch <- f.result()
+	}()
+	return ch
+}
+
+// Forget makes the next call for key run fn again instead of waiting for an
+// in-flight call or reusing a kept result.
+func (g *Singleflight[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.calls, key)
+}
+
+// forget removes the entry of key if it still holds f.
+func (g *Singleflight[K, V]) forget(key K, f *flight[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
+
+func (g *Singleflight[K, V]) start(ctx context.Context, key K, fn func(context.Context) (V, error), cfg *config, callerFrames []uintptr) *flight[V] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.calls[key]; ok && !f.expired() {
+		f.dups.Add(1)
+		return f
+	}
+	if g.calls == nil {
+		g.calls = make(map[K]*flight[V])
+	}
+
+	f := &flight[V]{done: make(chan struct{})}
+	g.calls[key] = f
+	fnCtx := context.WithoutCancel(ctx)
+	run := recoverWith(cfg, callerFrames, func() (err error) {
+		f.val, err = fn(fnCtx)
+		return err
+	})
+	go func() {
+		f.err = run()
+		g.mu.Lock()
+		if f.err == nil && g.TTL > 0 {
+			f.expires = time.Now().Add(g.TTL)
+			time.AfterFunc(g.TTL, func() { g.forget(key, f) })
+		} else if g.calls[key] == f {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// expired must be called with Singleflight.mu held.
+func (f *flight[V]) expired() bool {
+	select {
+	case <-f.done:
+		return time.Now().After(f.expires)
+	default:
+		return false
+	}
+}
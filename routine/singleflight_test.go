@@ -0,0 +1,159 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSingleflight(t *testing.T) {
+	suite.Run(t, new(TestSuiteSingleflight))
+}
+
+type TestSuiteSingleflight struct {
+	suite.Suite
+}
+
+func (s *TestSuiteSingleflight) TestDeduplicates() {
+	var g Singleflight[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	wg.Add(n)
+	for range n {
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.Do(context.Background(), "key", fn)
+			s.NoError(err)
+			s.Equal(42, v)
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	s.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.calls["key"] != nil && g.calls["key"].dups.Load() == n-1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	s.Equal(int32(1), calls.Load())
+	s.Equal(int32(n), sharedCount.Load())
+}
+
+func (s *TestSuiteSingleflight) TestPanicSharedByWaiters() {
+	var g Singleflight[string, int]
+	release := make(chan struct{})
+	first := g.DoChan(context.Background(), "key", func(context.Context) (int, error) {
+		<-release
+		panic("flight panic")
+	})
+	second := g.DoChan(context.Background(), "key", func(context.Context) (int, error) { return 1, nil })
+	close(release)
+
+	r1, r2 := <-first, <-second
+	var pe *PanicError
+	s.Require().ErrorAs(r1.Err, &pe)
+	s.Same(r1.Err, r2.Err)
+	s.True(r2.Shared)
+	s.Contains(pe.Error(), "singleflight_test.go")
+
+	// errors are not kept
+	v, err, _ := g.Do(context.Background(), "key", func(context.Context) (int, error) { return 2, nil })
+	s.NoError(err)
+	s.Equal(2, v)
+}
+
+func (s *TestSuiteSingleflight) TestContextAwareWaiting() {
+	var g Singleflight[string, int]
+	release := make(chan struct{})
+	defer close(release)
+	leader := g.DoChan(context.Background(), "key", func(context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err, _ := g.Do(ctx, "key", func(context.Context) (int, error) { return 2, nil })
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	select {
+	case <-leader:
+		s.Fail("leader should still be waiting")
+	default:
+	}
+}
+
+func (s *TestSuiteSingleflight) TestForget() {
+	var g Singleflight[string, int]
+	release := make(chan struct{})
+	first := g.DoChan(context.Background(), "key", func(context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	g.Forget("key")
+	v, err, shared := g.Do(context.Background(), "key", func(context.Context) (int, error) { return 2, nil })
+	s.NoError(err)
+	s.Equal(2, v)
+	s.False(shared)
+
+	close(release)
+	s.Equal(1, (<-first).Val)
+}
+
+func (s *TestSuiteSingleflight) TestTTL() {
+	g := Singleflight[string, int]{TTL: 50 * time.Millisecond}
+	var calls atomic.Int32
+	fn := func(context.Context) (int, error) { return int(calls.Add(1)), nil }
+
+	v, _, shared := g.Do(context.Background(), "key", fn)
+	s.Equal(1, v)
+	s.False(shared)
+	v, _, shared = g.Do(context.Background(), "key", fn)
+	s.Equal(1, v)
+	s.True(shared)
+
+	time.Sleep(60 * time.Millisecond)
+	v, _, _ = g.Do(context.Background(), "key", fn)
+	s.Equal(2, v)
+
+	g.Forget("key")
+	v, _, _ = g.Do(context.Background(), "key", fn)
+	s.Equal(3, v)
+
+	errFlight := errors.New("flight failed")
+	_, err, _ := g.Do(context.Background(), "err", func(context.Context) (int, error) { return 0, errFlight })
+	s.ErrorIs(err, errFlight)
+	v, err, _ = g.Do(context.Background(), "err", fn)
+	s.NoError(err)
+	s.Equal(4, v)
+}
+
+func (s *TestSuiteSingleflight) TestExpiredResultsRemoved() {
+	g := Singleflight[int, int]{TTL: 10 * time.Millisecond}
+	for i := range 100 {
+		_, err, _ := g.Do(context.Background(), i, func(context.Context) (int, error) { return i, nil })
+		s.Require().NoError(err)
+	}
+	s.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return len(g.calls) == 0
+	}, time.Second, time.Millisecond, "expired results are not kept")
+}
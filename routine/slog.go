@@ -0,0 +1,94 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// SlogHandler returns an error handler logging to logger at level, with the
+// details of a *PanicError, *LeakError or *StallError found in the error chain as
+// structured attributes: goroutine name, panic value, panic stack and caller
+// stack. Stacks are lists of "function file:line" strings. A nil logger uses
+// slog.Default at the time of each error.
+//
+// Use it per call with WithErrorHandler, or for every goroutine with
+// SetDefaultErrorHandler.
+func SlogHandler(logger *slog.Logger, level slog.Level) func(error) {
+	return func(err error) {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		msg, attrs := slogRecord(err)
+		l.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
+
+func slogRecord(err error) (string, []slog.Attr) {
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		attrs := []slog.Attr{
+			slog.String("goroutine", pe.Name),
+			slog.String("panic", pe.Err.Error()),
+		}
+		if len(pe.PanicStack) > 0 {
+			attrs = append(attrs, slog.Any("panic_stack", slogFrames(pe.PanicFrames())))
+		}
+		if len(pe.CallerStack) > 0 {
+			attrs = append(attrs, slog.Any("caller_stack", slogFrames(pe.CallerFrames())))
+		}
+		if pe.Original != nil {
+			attrs = append(attrs, slog.String("original_error", pe.Original.Error()))
+		}
+		if err != pe {
+			attrs = append(attrs, slog.String("error", errorPrefix(err, pe)))
+		}
+		return "routine: goroutine panicked", attrs
+	}
+
+	var le *LeakError
+	if errors.As(err, &le) {
+		attrs := []slog.Attr{
+			slog.String("goroutine", le.Name),
+			slog.Duration("grace", le.Grace),
+		}
+		if len(le.CallerStack) > 0 {
+			attrs = append(attrs, slog.Any("caller_stack", slogFrames(le.stack.runtimeFrames(le.CallerStack))))
+		}
+		return "routine: goroutine leaked", attrs
+	}
+
+	var se *StallError
+	if errors.As(err, &se) {
+		attrs := []slog.Attr{
+			slog.String("goroutine", se.Name),
+			slog.Uint64("goroutine_id", se.GoroutineID),
+			slog.Duration("running", se.Running),
+			slog.String("stack", se.Stack),
+		}
+		if len(se.CallerStack) > 0 {
+			attrs = append(attrs, slog.Any("caller_stack", slogFrames(se.stack.runtimeFrames(se.CallerStack))))
+		}
+		return "routine: goroutine stalled", attrs
+	}
+
+	return "routine: goroutine failed", []slog.Attr{slog.String("error", err.Error())}
+}
+
+// errorPrefix returns the message of err without the multi-line message of
+// the wrapped panic, e.g. `routine: stop hook "db"` for hook errors.
+func errorPrefix(err error, pe *PanicError) string {
+	return strings.TrimRight(strings.TrimSuffix(err.Error(), pe.Error()), ": ")
+}
+
+func slogFrames(frames []runtime.Frame) []string {
+	out := make([]string, 0, len(frames))
+	for _, f := range frames {
+		out = append(out, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package routine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSlog(t *testing.T) {
+	suite.Run(t, new(TestSuiteSlog))
+}
+
+type TestSuiteSlog struct {
+	suite.Suite
+}
+
+func (s *TestSuiteSlog) TestPanic() {
+	var buf bytes.Buffer
+	handler := SlogHandler(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelError)
+
+	err := Recover(func() error { panic("slog panic") }, WithName("worker"))()
+	handler(err)
+
+	rec := s.decode(&buf)
+	s.Equal("ERROR", rec["level"])
+	s.Equal("routine: goroutine panicked", rec["msg"])
+	s.Equal("worker", rec["goroutine"])
+	s.Equal("slog panic", rec["panic"])
+	s.Contains(fmt.Sprint(rec["panic_stack"]), "slog_test.go")
+	s.Contains(fmt.Sprint(rec["caller_stack"]), "TestPanic")
+	s.NotContains(rec, "error")
+}
+
+func (s *TestSuiteSlog) TestWrappedPanic() {
+	var buf bytes.Buffer
+	handler := SlogHandler(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelWarn)
+
+	err := Recover(func() error { panic("hook panic") })()
+	handler(fmt.Errorf("routine: stop hook %q: %w", "db", err))
+
+	rec := s.decode(&buf)
+	s.Equal("WARN", rec["level"])
+	s.Equal("hook panic", rec["panic"])
+	s.Equal(`routine: stop hook "db"`, rec["error"])
+}
+
+func (s *TestSuiteSlog) TestLeakAndPlainErrors() {
+	var buf bytes.Buffer
+	handler := SlogHandler(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelError)
+
+	handler(&LeakError{Name: "stuck", Grace: time.Second})
+	rec := s.decode(&buf)
+	s.Equal("routine: goroutine leaked", rec["msg"])
+	s.Equal("stuck", rec["goroutine"])
+
+	buf.Reset()
+	handler(errors.New("plain"))
+	rec = s.decode(&buf)
+	s.Equal("routine: goroutine failed", rec["msg"])
+	s.Equal("plain", rec["error"])
+}
+
+func (s *TestSuiteSlog) TestSetDefaultErrorHandler() {
+	var buf bytes.Buffer
+	handler := SlogHandler(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelError)
+	logged := make(chan struct{})
+	SetDefaultErrorHandler(func(err error) {
+		handler(err)
+		close(logged)
+	})
+	defer SetDefaultErrorHandler(nil)
+
+	Go(func() { panic("default panic") }, WithName("defaulted"))
+	<-logged
+	rec := s.decode(&buf)
+	s.Equal("default panic", rec["panic"])
+	s.Equal("defaulted", rec["goroutine"])
+}
+
+func (s *TestSuiteSlog) decode(buf *bytes.Buffer) map[string]any {
+	var rec map[string]any
+	s.Require().NoError(json.Unmarshal(buf.Bytes(), &rec), buf.String())
+	return rec
+}
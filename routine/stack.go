@@ -3,26 +3,146 @@ package routine
 import (
 	"fmt"
 	"runtime"
+	"strings"
 )
 
-// captureStack captures a formatted stack trace, skipping the specified
-// number of frames above the caller. skip=0 starts at the caller of captureStack.
-func captureStack(skip int) string {
-	var pcs [32]uintptr
-	// +2: skip runtime.Callers + captureStack itself
-	n := runtime.Callers(2+skip, pcs[:])
-	return formatFrames(pcs[:n])
+// FrameFilter reports whether a frame is kept in formatted stacks.
+type FrameFilter func(runtime.Frame) bool
+
+// DropRuntimeFrames drops frames of package runtime, such as runtime.gopanic
+// and runtime.goexit.
+func DropRuntimeFrames() FrameFilter {
+	return func(f runtime.Frame) bool { return !strings.HasPrefix(f.Function, "runtime.") }
+}
+
+// DropPackages drops frames whose function belongs to a package path
+// starting with any of the prefixes, e.g. "github.com/stretchr/testify/".
+func DropPackages(prefixes ...string) FrameFilter {
+	return func(f runtime.Frame) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(f.Function, p) {
+				return false
+			}
+		}
+		return true
+	}
 }
 
-func formatFrames(pcs []uintptr) string {
+// stackConfig controls how stacks are captured and formatted.
+// The zero value captures every frame and formats them unchanged.
+type stackConfig struct {
+	maxDepth     int
+	filters      []FrameFilter
+	trimPrefixes []string
+	collapse     bool
+}
+
+// stackFrame is a frame after filtering; repeat counts the directly
+// recursive frames collapsed into it.
+type stackFrame struct {
+	runtime.Frame
+	repeat int
+}
+
+// callers returns the program counters of the calling goroutine, skipping the
+// specified number of frames above the caller. skip=0 starts at the caller of
+// callers. maxDepth limits the number of frames, zero means unbounded.
+func callers(skip, maxDepth int) []uintptr {
+	size := 32
+	if maxDepth > 0 {
+		size = maxDepth
+	}
+	for {
+		pcs := make([]uintptr, size)
+		// +2: skip runtime.Callers + callers itself
+		n := runtime.Callers(2+skip, pcs)
+		if n < size || maxDepth > 0 {
+			return pcs[:n]
+		}
+		size *= 2
+	}
+}
+
+// frames resolves pcs and applies filters, path trimming and recursion
+// collapsing. A nil config applies none of them.
+func (sc *stackConfig) frames(pcs []uintptr) []stackFrame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var out []stackFrame
 	frames := runtime.CallersFrames(pcs)
-	var buf []byte
 	for {
 		frame, more := frames.Next()
-		buf = fmt.Appendf(buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if sc.keep(frame) {
+			if sc != nil {
+				for _, p := range sc.trimPrefixes {
+					if rest, ok := strings.CutPrefix(frame.File, p); ok {
+						frame.File = rest
+						break
+					}
+				}
+			}
+			if sc != nil && sc.collapse && len(out) > 0 && out[len(out)-1].Function == frame.Function {
+				out[len(out)-1].repeat++
+			} else {
+				out = append(out, stackFrame{Frame: frame})
+			}
+		}
 		if !more {
 			break
 		}
 	}
+	return out
+}
+
+func (sc *stackConfig) keep(frame runtime.Frame) bool {
+	if sc == nil {
+		return true
+	}
+	for _, f := range sc.filters {
+		if !f(frame) {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc *stackConfig) format(pcs []uintptr) string {
+	var buf []byte
+	for _, frame := range sc.frames(pcs) {
+		buf = fmt.Appendf(buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if frame.repeat > 0 {
+			buf = fmt.Appendf(buf, "\t... %d more recursive calls\n", frame.repeat)
+		}
+	}
 	return string(buf)
 }
+
+// runtimeFrames returns the processed frames without recursion counts.
+func (sc *stackConfig) runtimeFrames(pcs []uintptr) []runtime.Frame {
+	frames := sc.frames(pcs)
+	if frames == nil {
+		return nil
+	}
+	out := make([]runtime.Frame, len(frames))
+	for i, f := range frames {
+		out[i] = f.Frame
+	}
+	return out
+}
+
+func formatFrames(pcs []uintptr) string { return (*stackConfig)(nil).format(pcs) }
+
+func callersFrames(pcs []uintptr) []runtime.Frame { return (*stackConfig)(nil).runtimeFrames(pcs) }
+
+// allStacks returns the stacks of all goroutines, as runtime.Stack.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
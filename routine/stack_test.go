@@ -0,0 +1,87 @@
+package routine
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestStack(t *testing.T) {
+	suite.Run(t, new(TestSuiteStack))
+}
+
+type TestSuiteStack struct {
+	suite.Suite
+}
+
+func (s *TestSuiteStack) TestDropRuntimeFrames() {
+	err := Recover(func() error { panic("boom") })()
+	s.Contains(err.Error(), "runtime.goexit")
+
+	err = Recover(func() error { panic("boom") }, WithFrameFilter(DropRuntimeFrames()))()
+	s.NotContains(err.Error(), "runtime.")
+	s.Contains(err.Error(), "stack_test.go")
+}
+
+func (s *TestSuiteStack) TestDropPackages() {
+	err := Recover(func() error { panic("boom") },
+		WithFrameFilter(DropPackages("github.com/stretchr/testify/", "reflect.")))()
+	s.NotContains(err.Error(), "testify")
+	s.NotContains(err.Error(), "reflect.Value")
+
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	for _, f := range pe.CallerFrames() {
+		s.NotContains(f.Function, "testify")
+	}
+}
+
+func (s *TestSuiteStack) TestTrimPathPrefix() {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Dir(file) + "/"
+
+	err := Recover(func() error { panic("boom") }, WithTrimPathPrefix(dir))()
+	s.NotContains(err.Error(), dir+"stack_test.go")
+	s.Contains(err.Error(), "\tstack_test.go:")
+}
+
+func (s *TestSuiteStack) TestCollapseRecursion() {
+	err := Recover(func() error { recurse(20); return nil }, WithCollapseRecursion(true))()
+	s.Equal(1, strings.Count(err.Error(), "routine.recurse\n"))
+	s.Contains(err.Error(), "... 20 more recursive calls")
+
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	var n int
+	for _, f := range pe.PanicFrames() {
+		if strings.HasSuffix(f.Function, ".recurse") {
+			n++
+		}
+	}
+	s.Equal(1, n)
+}
+
+func (s *TestSuiteStack) TestUnboundedCapture() {
+	err := Recover(func() error { recurse(100); return nil })()
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Greater(len(pe.PanicStack), 100)
+}
+
+func (s *TestSuiteStack) TestMaxDepth() {
+	err := Recover(func() error { recurse(100); return nil }, WithMaxDepth(3))()
+	var pe *PanicError
+	s.Require().ErrorAs(err, &pe)
+	s.Len(pe.PanicStack, 3)
+	s.LessOrEqual(len(pe.CallerStack), 3)
+}
+
+func recurse(n int) {
+	if n == 0 {
+		panic("bottom")
+	}
+	recurse(n - 1)
+}
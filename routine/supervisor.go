@@ -0,0 +1,409 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManyRestarts is returned by Supervisor.Wait when children failed
+	// more often than the restart intensity allows and the supervisor gave up.
+	ErrTooManyRestarts = errors.New("routine: supervisor reached maximum restart intensity")
+	// ErrSupervisorStopped is returned by Supervisor.Add once the supervisor
+	// has stopped, through Shutdown or by giving up.
+	ErrSupervisorStopped = errors.New("routine: supervisor is stopped")
+)
+
+// Strategy decides which children a Supervisor restarts when one fails.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child.
+	OneForOne Strategy = iota
+	// OneForAll stops and restarts every child.
+	OneForAll
+	// RestForOne stops and restarts the failed child and every child added
+	// after it.
+	RestForOne
+)
+
+// ChildState is the lifecycle state of a supervised child.
+type ChildState int
+
+const (
+	ChildPending    ChildState = iota // added but the supervisor is not started
+	ChildRunning                      // running
+	ChildRestarting                   // failed or stopped by its strategy, waiting to restart
+	ChildExited                       // returned nil, not restarted
+	ChildStopped                      // stopped by Shutdown or after giving up
+)
+
+func (s ChildState) String() string {
+	switch s {
+	case ChildPending:
+		return "pending"
+	case ChildRunning:
+		return "running"
+	case ChildRestarting:
+		return "restarting"
+	case ChildExited:
+		return "exited"
+	case ChildStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("ChildState(%d)", int(s))
+	}
+}
+
+// ChildStatus is a snapshot of a supervised child.
+type ChildStatus struct {
+	Name      string
+	State     ChildState
+	Restarts  int
+	LastError error
+}
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*supervisorConfig)
+
+type supervisorConfig struct {
+	strategy    Strategy
+	maxRestarts int
+	period      time.Duration
+	backoff     Backoff
+	clock       Clock
+	options     []Option
+}
+
+// WithStrategy sets the restart strategy. Defaults to OneForOne.
+func WithStrategy(s Strategy) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.strategy = s
+	}
+}
+
+// WithRestartIntensity makes the supervisor give up when more than
+// maxRestarts restarts happen within period. Defaults to 3 in 5 seconds.
+func WithRestartIntensity(maxRestarts int, period time.Duration) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.maxRestarts = maxRestarts
+		c.period = period
+	}
+}
+
+// WithRestartBackoff delays restarts. The attempt passed to b is the number
+// of restarts within the current intensity period, and the previous delay is
+// the last restart delay, zero once the period had no restarts. Defaults to
+// no delay.
+func WithRestartBackoff(b Backoff) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.backoff = b
+	}
+}
+
+// WithChildOptions sets Recover options for every child. Child failures are
+// reported to the error handler before being restarted. WithClock also sets
+// the clock of restart intensity and backoff.
+func WithChildOptions(options ...Option) SupervisorOption {
+	return func(c *supervisorConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// Supervisor runs named long-running children and restarts them when they
+// return an error or panic, in the style of Erlang/OTP supervisors.
+// A child returning nil is considered done and is not restarted.
+type Supervisor struct {
+	cfg supervisorConfig
+
+	mu       sync.Mutex
+	children []*child
+	restarts []time.Time
+	delay    time.Duration // last restart delay, reset with the intensity window
+	parent   context.Context
+	loopCtx  context.Context
+	stopLoop context.CancelFunc
+	loopDone chan struct{}
+	err      error
+
+	events chan childExit
+}
+
+type child struct {
+	name    string
+	run     func() error
+	handler func(error)
+
+	ctx    context.Context // read by run, written only while stopped
+	cancel context.CancelFunc
+	done   chan struct{}
+	gen    int
+
+	state    ChildState
+	restarts int
+	lastErr  error
+}
+
+type childExit struct {
+	c   *child
+	gen int
+	err error
+}
+
+// NewSupervisor returns a supervisor that is not started yet.
+func NewSupervisor(options ...SupervisorOption) *Supervisor {
+	cfg := supervisorConfig{maxRestarts: 3, period: 5 * time.Second}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	childCfg := newConfig(cfg.options)
+	cfg.clock = childCfg.timer()
+	return &Supervisor{cfg: cfg, events: make(chan childExit), loopDone: make(chan struct{})}
+}
+
+// Add registers a child. Children start in the order they are added; a child
+// added after Start starts immediately. It returns ErrSupervisorStopped once
+// the supervisor has stopped.
+func (s *Supervisor) Add(name string, fn func(context.Context) error, options ...Option) error {
+	// WithCallerSkip(1) skips Add, so panics point back to where the child
+	// was registered; supervisor and per-child options follow.
+	opts := make([]Option, 0, 2+len(s.cfg.options)+len(options))
+	opts = append(opts, WithCallerSkip(1), WithName(name))
+	opts = append(opts, s.cfg.options...)
+	opts = append(opts, options...)
+	cfg := newConfig(opts)
+
+	c := &child{name: name, handler: cfg.handler()}
+	c.run = Recover(func() error { return fn(c.ctx) }, opts...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loopCtx != nil && s.loopCtx.Err() != nil {
+		return ErrSupervisorStopped
+	}
+	s.children = append(s.children, c)
+	if s.loopCtx != nil {
+		s.startChild(c)
+	}
+	return nil
+}
+
+// Start launches every child and the supervision loop. Children run with
+// contexts derived from ctx; canceling ctx stops them all without ordering,
+// use Shutdown for an ordered stop.
+func (s *Supervisor) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loopCtx != nil {
+		return
+	}
+	s.parent = ctx
+	s.loopCtx, s.stopLoop = context.WithCancel(ctx)
+	for _, c := range s.children {
+		s.startChild(c)
+	}
+	go s.loop()
+}
+
+// Status returns a snapshot of every child, in the order they were added.
+func (s *Supervisor) Status() []ChildStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ChildStatus, 0, len(s.children))
+	for _, c := range s.children {
+		out = append(out, ChildStatus{Name: c.name, State: c.state, Restarts: c.restarts, LastError: c.lastErr})
+	}
+	return out
+}
+
+// Wait blocks until the supervisor stops, either through Shutdown or by
+// giving up. It returns an error wrapping ErrTooManyRestarts and the last
+// child error in the latter case. Before Start, it returns nil at once.
+func (s *Supervisor) Wait() error {
+	s.mu.Lock()
+	started := s.loopCtx != nil
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+	<-s.loopDone
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Shutdown stops the supervision loop, then stops the children one by one in
+// reverse order, waiting for each to return before stopping the next.
+// It returns early with an error naming the child that did not stop in time
+// if ctx is done.
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	started := s.loopCtx != nil
+	if started {
+		s.stopLoop()
+	}
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-s.loopDone:
+	case <-ctx.Done():
+		return fmt.Errorf("routine: supervisor loop did not stop: %w", ctx.Err())
+	}
+	return s.stopChildren(ctx)
+}
+
+func (s *Supervisor) loop() {
+	defer close(s.loopDone)
+	for {
+		select {
+		case <-s.loopCtx.Done():
+			return
+		case ev := <-s.events:
+			if !s.handleExit(ev) {
+				return
+			}
+		}
+	}
+}
+
+// handleExit applies the strategy to a child exit. It returns false when the
+// supervisor gave up.
+func (s *Supervisor) handleExit(ev childExit) bool {
+	s.mu.Lock()
+	c := ev.c
+	if ev.gen != c.gen {
+		// stopped on purpose by a strategy or Shutdown
+		s.mu.Unlock()
+		return true
+	}
+	if ev.err == nil {
+		c.state = ChildExited
+		s.mu.Unlock()
+		return true
+	}
+	c.lastErr = ev.err
+	s.mu.Unlock()
+	c.handler(ev.err)
+
+	s.mu.Lock()
+	now := s.cfg.clock.Now()
+	s.restarts = slices.DeleteFunc(s.restarts, func(t time.Time) bool { return now.Sub(t) >= s.cfg.period })
+	if len(s.restarts) == 0 {
+		s.delay = 0
+	}
+	if len(s.restarts) >= s.cfg.maxRestarts {
+		s.err = fmt.Errorf("%w: child %q: %w", ErrTooManyRestarts, c.name, ev.err)
+		s.stopLoop()
+		s.mu.Unlock()
+		s.stopChildren(context.Background())
+		return false
+	}
+	s.restarts = append(s.restarts, now)
+	if s.cfg.backoff != nil {
+		s.delay = s.cfg.backoff.Next(len(s.restarts), s.delay)
+	}
+	delay := s.delay
+
+	from := slices.Index(s.children, c)
+	var victims []*child
+	switch s.cfg.strategy {
+	case OneForAll:
+		victims = slices.Clone(s.children)
+	case RestForOne:
+		victims = slices.Clone(s.children[from:])
+	default:
+		victims = []*child{c}
+	}
+	for _, v := range victims {
+		if v.state == ChildRunning && v != c {
+			v.gen++
+			v.cancel()
+		}
+		v.state = ChildRestarting
+	}
+	s.mu.Unlock()
+
+	// wait for the stopped siblings in reverse order, then restart in order
+	for _, v := range slices.Backward(victims) {
+		select {
+		case <-v.done:
+		case <-s.loopCtx.Done():
+			return false
+		}
+	}
+	if delay > 0 && sleep(s.loopCtx, s.cfg.clock, delay) != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loopCtx.Err() != nil {
+		return false
+	}
+	for _, v := range victims {
+		if v.state == ChildRestarting {
+			v.restarts++
+			s.startChild(v)
+		}
+	}
+	return true
+}
+
+// startChild must be called with s.mu held.
+func (s *Supervisor) startChild(c *child) {
+	c.gen++
+	ctx, cancel := context.WithCancel(s.parent)
+	c.ctx, c.cancel = ctx, cancel
+	c.done = make(chan struct{})
+	c.state = ChildRunning
+	ev, done := childExit{c: c, gen: c.gen}, c.done
+	go func() {
+		ev.err = c.run()
+		// release the context at once, as a restart derives a new one
+		cancel()
+		close(done)
+		select {
+		case s.events <- ev:
+		case <-s.loopDone:
+		}
+	}()
+}
+
+// stopChildren stops every child in reverse order.
+func (s *Supervisor) stopChildren(ctx context.Context) error {
+	s.mu.Lock()
+	children := slices.Clone(s.children)
+	s.mu.Unlock()
+
+	for _, c := range slices.Backward(children) {
+		s.mu.Lock()
+		running := c.state == ChildRunning || c.state == ChildRestarting
+		done := c.done
+		if c.cancel != nil {
+			c.gen++
+			c.cancel()
+		}
+		s.mu.Unlock()
+
+		if running && done != nil {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return fmt.Errorf("routine: supervisor child %q did not stop: %w", c.name, ctx.Err())
+			}
+		}
+		s.mu.Lock()
+		if c.state != ChildExited {
+			c.state = ChildStopped
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package routine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSupervisor(t *testing.T) {
+	suite.Run(t, new(TestSuiteSupervisor))
+}
+
+type TestSuiteSupervisor struct {
+	suite.Suite
+}
+
+var errChildFailed = errors.New("child failed")
+
+// failingChild fails the first n runs, then blocks until canceled.
+func failingChild(n int32, starts *atomic.Int32) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if starts.Add(1) <= n {
+			return errChildFailed
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
+
+func blockingChild(starts *atomic.Int32) func(context.Context) error {
+	return failingChild(0, starts)
+}
+
+func (s *TestSuiteSupervisor) newSupervisor(options ...SupervisorOption) *Supervisor {
+	options = append([]SupervisorOption{WithChildOptions(WithErrorHandler(func(error) {}))}, options...)
+	return NewSupervisor(options...)
+}
+
+func (s *TestSuiteSupervisor) TestOneForOne() {
+	var a, b atomic.Int32
+	sup := s.newSupervisor()
+	sup.Add("a", blockingChild(&a))
+	sup.Add("b", failingChild(2, &b))
+	sup.Start(context.Background())
+
+	s.Eventually(func() bool { return b.Load() == 3 }, time.Second, 10*time.Millisecond)
+	s.Equal(int32(1), a.Load())
+
+	status := sup.Status()
+	s.Equal(ChildRunning, status[1].State)
+	s.Equal(2, status[1].Restarts)
+	s.ErrorIs(status[1].LastError, errChildFailed)
+
+	s.NoError(sup.Shutdown(context.Background()))
+	s.NoError(sup.Wait())
+	for _, st := range sup.Status() {
+		s.Equal(ChildStopped, st.State, st.Name)
+	}
+}
+
+func (s *TestSuiteSupervisor) TestOneForAll() {
+	var a, b, c atomic.Int32
+	sup := s.newSupervisor(WithStrategy(OneForAll))
+	sup.Add("a", blockingChild(&a))
+	sup.Add("b", failingChild(1, &b))
+	sup.Add("c", blockingChild(&c))
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	s.Eventually(func() bool { return a.Load() == 2 && b.Load() == 2 && c.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *TestSuiteSupervisor) TestRestForOne() {
+	var a, b, c atomic.Int32
+	sup := s.newSupervisor(WithStrategy(RestForOne))
+	sup.Add("a", blockingChild(&a))
+	sup.Add("b", failingChild(1, &b))
+	sup.Add("c", blockingChild(&c))
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	s.Eventually(func() bool { return b.Load() == 2 && c.Load() == 2 }, time.Second, 10*time.Millisecond)
+	s.Equal(int32(1), a.Load())
+}
+
+func (s *TestSuiteSupervisor) TestRestartsAfterPanic() {
+	errCh := make(chan error, 1)
+	var starts atomic.Int32
+	sup := NewSupervisor(WithChildOptions(WithErrorHandler(func(err error) { errCh <- err })))
+	sup.Add("panicky", func(ctx context.Context) error {
+		if starts.Add(1) == 1 {
+			panic("child panic")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	var pe *PanicError
+	s.ErrorAs(<-errCh, &pe)
+	s.Eventually(func() bool { return starts.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *TestSuiteSupervisor) TestRestartIntensity() {
+	var starts atomic.Int32
+	sup := s.newSupervisor(WithRestartIntensity(2, time.Minute))
+	sup.Add("broken", failingChild(100, &starts))
+	sup.Add("healthy", blockingChild(new(atomic.Int32)))
+	sup.Start(context.Background())
+
+	err := sup.Wait()
+	s.ErrorIs(err, ErrTooManyRestarts)
+	s.ErrorIs(err, errChildFailed)
+	s.Equal(int32(3), starts.Load())
+	for _, st := range sup.Status() {
+		s.Equal(ChildStopped, st.State, st.Name)
+	}
+	var late atomic.Int32
+	s.ErrorIs(sup.Add("late", blockingChild(&late)), ErrSupervisorStopped)
+	s.NoError(sup.Shutdown(context.Background()))
+	s.Zero(late.Load(), "no child starts after giving up")
+}
+
+func (s *TestSuiteSupervisor) TestAddAfterShutdown() {
+	sup := s.newSupervisor()
+	s.NoError(sup.Wait(), "Wait returns at once before Start")
+	s.NoError(sup.Add("a", blockingChild(new(atomic.Int32))))
+	sup.Start(context.Background())
+	s.NoError(sup.Shutdown(context.Background()))
+	s.ErrorIs(sup.Add("b", blockingChild(new(atomic.Int32))), ErrSupervisorStopped)
+	s.Len(sup.Status(), 1)
+}
+
+func (s *TestSuiteSupervisor) TestRestartBackoff() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var starts atomic.Int32
+	sup := s.newSupervisor(WithRestartBackoff(ConstantBackoff(time.Minute)), WithChildOptions(WithClock(clock)))
+	sup.Add("slow", failingChild(1, &starts))
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	clock.BlockUntil(1)
+	s.Equal(int32(1), starts.Load())
+	s.Equal(ChildRestarting, sup.Status()[0].State)
+	clock.Advance(time.Minute)
+	s.Eventually(func() bool { return starts.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *TestSuiteSupervisor) TestDecorrelatedBackoff() {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var mu sync.Mutex
+	var prevs, delays []time.Duration
+	jitter := ExponentialBackoff(time.Second, time.Hour, DecorrelatedJitter)
+	backoff := BackoffFunc(func(attempt int, prev time.Duration) time.Duration {
+		d := jitter.Next(attempt, prev)
+		mu.Lock()
+		defer mu.Unlock()
+		prevs, delays = append(prevs, prev), append(delays, d)
+		return d
+	})
+	var starts atomic.Int32
+	sup := s.newSupervisor(WithRestartBackoff(backoff), WithRestartIntensity(10, time.Hour), WithChildOptions(WithClock(clock)))
+	sup.Add("flaky", failingChild(4, &starts))
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	for i := range 4 {
+		clock.BlockUntil(1)
+		mu.Lock()
+		s.Require().Len(delays, i+1)
+		d := delays[i]
+		mu.Unlock()
+		clock.Advance(d)
+	}
+	s.Eventually(func() bool { return starts.Load() == 5 }, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.Zero(prevs[0])
+	for i := 1; i < len(delays); i++ {
+		s.Equal(delays[i-1], prevs[i], "each delay grows from the previous one")
+	}
+	s.Greater(slices.Max(delays), time.Second)
+}
+
+func (s *TestSuiteSupervisor) TestChildContextReleased() {
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctxs := make(chan context.Context, 10)
+	var starts atomic.Int32
+	sup := s.newSupervisor(WithRestartIntensity(10, time.Minute))
+	sup.Add("flaky", func(ctx context.Context) error {
+		ctxs <- ctx
+		return failingChild(3, &starts)(ctx)
+	})
+	sup.Add("done", func(ctx context.Context) error {
+		ctxs <- ctx
+		return nil
+	})
+	sup.Start(parent)
+	defer sup.Shutdown(context.Background())
+
+	s.Eventually(func() bool { return starts.Load() == 4 && len(ctxs) == 5 }, time.Second, time.Millisecond)
+	close(ctxs)
+	var all []context.Context
+	for ctx := range ctxs {
+		all = append(all, ctx)
+	}
+	s.Eventually(func() bool {
+		ended := 0
+		for _, ctx := range all {
+			if ctx.Err() != nil {
+				ended++
+			}
+		}
+		return ended == 4
+	}, time.Second, time.Millisecond, "only the running child keeps its context")
+}
+
+func (s *TestSuiteSupervisor) TestOrderedShutdown() {
+	var mu sync.Mutex
+	var order []string
+	child := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	sup := s.newSupervisor()
+	sup.Add("a", child("a"))
+	sup.Add("b", child("b"))
+	sup.Add("c", child("c"))
+	sup.Start(context.Background())
+
+	s.NoError(sup.Shutdown(context.Background()))
+	s.Equal([]string{"c", "b", "a"}, order)
+}
+
+func (s *TestSuiteSupervisor) TestShutdownTimeout() {
+	release := make(chan struct{})
+	defer close(release)
+	sup := s.newSupervisor()
+	sup.Add("stubborn", func(context.Context) error {
+		<-release
+		return nil
+	})
+	sup.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := sup.Shutdown(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Contains(err.Error(), `"stubborn"`)
+}
+
+func (s *TestSuiteSupervisor) TestChildExitedIsNotRestarted() {
+	var starts atomic.Int32
+	sup := s.newSupervisor()
+	sup.Add("oneshot", func(context.Context) error {
+		starts.Add(1)
+		return nil
+	})
+	sup.Start(context.Background())
+	defer sup.Shutdown(context.Background())
+
+	s.Eventually(func() bool { return sup.Status()[0].State == ChildExited }, time.Second, 10*time.Millisecond)
+	s.Equal(int32(1), starts.Load())
+}
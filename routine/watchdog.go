@@ -0,0 +1,96 @@
+package routine
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// StallError is reported by the watchdog enabled with WithWatchdog when a
+// goroutine runs longer than its budget.
+type StallError struct {
+	Name string
+	// GoroutineID is the runtime id of the stalled goroutine.
+	GoroutineID uint64
+	// Running is how long the goroutine had been running.
+	Running time.Duration
+	// Stack is the current stack of the goroutine in runtime.Stack format,
+	// showing where it is blocked. Empty if it returned meanwhile.
+	Stack string
+	// CallerStack holds the program counters of the call site of Go or GoCtx.
+	CallerStack []uintptr
+
+	stack *stackConfig
+}
+
+func (e *StallError) Error() string {
+	msg := fmt.Appendf(nil, "goroutine %q still running after %s", e.Name, e.Running.Round(time.Millisecond))
+	if e.Stack != "" {
+		msg = fmt.Appendf(msg, "\n\n%s\n", e.Stack)
+	}
+	if len(e.CallerStack) > 0 {
+		msg = fmt.Appendf(msg, "\ncalled from:\n\n%s", e.stack.format(e.CallerStack))
+	}
+	return string(msg)
+}
+
+// StallObserver is implemented by observers that also want the reports of
+// the watchdog enabled with WithWatchdog.
+type StallObserver interface {
+	OnStall(name string, err *StallError)
+}
+
+// startWatchdog must be called from the watched goroutine. It returns a
+// function to call when the goroutine is done.
+func startWatchdog(cfg *config, launch []uintptr, errHandler func(error)) (stop func()) {
+	if cfg.stallBudget <= 0 {
+		return func() {}
+	}
+	id, start := goid(), time.Now()
+	observers := observersFor(cfg)
+
+	var finished atomic.Bool
+	var timer atomic.Pointer[time.Timer]
+	var check func()
+	check = func() {
+		if finished.Load() {
+			return
+		}
+		err := &StallError{
+			Name:        cfg.name,
+			GoroutineID: id,
+			Running:     time.Since(start),
+			Stack:       goroutineStack(id),
+			CallerStack: launch,
+			stack:       &cfg.stack,
+		}
+		for _, o := range observers {
+			if so, ok := o.(StallObserver); ok {
+				so.OnStall(cfg.name, err)
+			}
+		}
+		errHandler(err)
+		if cfg.stallRepeat > 0 && !finished.Load() {
+			timer.Store(time.AfterFunc(cfg.stallRepeat, check))
+		}
+	}
+	timer.Store(time.AfterFunc(cfg.stallBudget, check))
+	return func() {
+		finished.Store(true)
+		timer.Load().Stop()
+	}
+}
+
+// goroutineStack returns the stack of the goroutine with the given id, taken
+// from a dump of all goroutines, or "" if it no longer exists.
+func goroutineStack(id uint64) string {
+	buf := allStacks()
+	header := fmt.Appendf(nil, "goroutine %d [", id)
+	for block := range bytes.SplitSeq(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(block, header) {
+			return string(bytes.TrimSpace(block))
+		}
+	}
+	return ""
+}
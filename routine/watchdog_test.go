@@ -0,0 +1,112 @@
+package routine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWatchdog(t *testing.T) {
+	suite.Run(t, new(TestSuiteWatchdog))
+}
+
+type TestSuiteWatchdog struct {
+	suite.Suite
+}
+
+func (s *TestSuiteWatchdog) TestReportsBlockedGoroutine() {
+	errCh := make(chan error, 1)
+	release := make(chan struct{})
+	blocked := make(chan int)
+	Go(func() {
+		select {
+		case blocked <- 1:
+		case <-release:
+		}
+	}, WithName("sender"), WithWatchdog(20*time.Millisecond, 0), WithErrorHandler(func(err error) { errCh <- err }))
+	defer close(release)
+
+	err := <-errCh
+	s.T().Logf("stall error:\n%s", err.Error())
+	se, ok := err.(*StallError)
+	s.Require().True(ok)
+	s.Equal("sender", se.Name)
+	s.NotZero(se.GoroutineID)
+	s.GreaterOrEqual(se.Running, 20*time.Millisecond)
+	s.Contains(se.Stack, "[select]")
+	s.Contains(se.Stack, "TestReportsBlockedGoroutine")
+	s.Contains(err.Error(), `goroutine "sender" still running after`)
+	s.Contains(err.Error(), "called from:")
+
+	select {
+	case err := <-errCh:
+		s.Failf("reported again without repeat", "%v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *TestSuiteWatchdog) TestRepeatAndObserver() {
+	var mu sync.Mutex
+	var reports []time.Duration
+	handler := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, err.(*StallError).Running)
+	}
+	obs := &stallRecorder{stalls: make(chan *StallError, 16)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	GoCtx(ctx, func(ctx context.Context) error {
+		defer close(done)
+		<-ctx.Done()
+		return nil
+	}, WithWatchdog(10*time.Millisecond, 10*time.Millisecond), WithErrorHandler(handler), WithObserver(obs))
+
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reports) >= 3
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	s.NotEmpty(obs.stalls)
+
+	mu.Lock()
+	n := len(reports)
+	s.Less(reports[0], reports[n-1])
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	s.LessOrEqual(len(reports), n+1, "watchdog kept reporting after return")
+}
+
+func (s *TestSuiteWatchdog) TestFastGoroutineNotReported() {
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	Go(func() { close(done) }, WithWatchdog(20*time.Millisecond, 0), WithErrorHandler(func(err error) { errCh <- err }))
+	<-done
+	select {
+	case err := <-errCh:
+		s.Failf("fast goroutine reported", "%v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *TestSuiteWatchdog) TestGoroutineStack() {
+	s.Contains(goroutineStack(goid()), "TestGoroutineStack")
+	s.Empty(goroutineStack(0))
+}
+
+type stallRecorder struct {
+	stalls chan *StallError
+}
+
+func (r *stallRecorder) OnStart(string)                        {}
+func (r *stallRecorder) OnFinish(string, time.Duration, error) {}
+func (r *stallRecorder) OnPanic(string, *PanicError)           {}
+func (r *stallRecorder) OnStall(_ string, err *StallError)     { r.stalls <- err }